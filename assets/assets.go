@@ -0,0 +1,18 @@
+package assets
+
+import "embed"
+
+// 打包进程序的资源文件（模型等），路径以 models/ 开头
+//
+//go:embed models
+var Models embed.FS
+
+// 副本时间的数字模板，路径以 digits/ 开头
+//
+//go:embed digits
+var Digits embed.FS
+
+// 界面图标的图片模板，路径以 templates/ 开头
+//
+//go:embed templates
+var Templates embed.FS
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"star-map-tool/internal/detector"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// 颜色校准命令的参数，区域的格式与预设一致: x1,y1,x2,y2 (截图中的坐标)
+type calibrateFlags struct {
+	Region       image.Rectangle   // 截取的区域，为空时使用整张截图
+	Positive     []image.Rectangle // 目标区域
+	Negative     []image.Rectangle // 背景区域，为空时目标以外的部分都视为背景
+	PositiveMask string            // 目标掩码图片，也可以是与截图同名的掩码所在的目录
+	NegativeMask string            // 背景掩码图片或目录
+	Name         string            // 预设的变量名
+	Files        []string
+}
+
+func parseCalibrateFlags(args []string) (*calibrateFlags, error) {
+	f := &calibrateFlags{}
+
+	fs := flag.NewFlagSet("calibrate-color", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: maptool calibrate-color [参数] 截图.png...")
+		fs.PrintDefaults()
+	}
+	fs.Func("region", "截取的区域，例如: 494,51,799,72 (默认整张截图)", func(value string) (err error) {
+		f.Region, err = parseArea(value)
+		return err
+	})
+	fs.Func("positive", "目标区域，可以指定多次，例如: 500,55,700,68", func(value string) error {
+		rect, err := parseArea(value)
+		f.Positive = append(f.Positive, rect)
+		return err
+	})
+	fs.Func("negative", "背景区域，可以指定多次 (默认目标以外的部分都是背景)", func(value string) error {
+		rect, err := parseArea(value)
+		f.Negative = append(f.Negative, rect)
+		return err
+	})
+	fs.StringVar(&f.PositiveMask, "positive-mask", "", "目标掩码图片(白色为目标)，或存放与截图同名掩码的目录")
+	fs.StringVar(&f.NegativeMask, "negative-mask", "", "背景掩码图片(白色为背景)，或存放与截图同名掩码的目录")
+	fs.StringVar(&f.Name, "name", "Color", "预设的变量名，例如: BossHealthColor")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	f.Files = fs.Args()
+	if len(f.Files) == 0 {
+		return nil, errors.New("缺少截图")
+	}
+	if len(f.Positive) == 0 && f.PositiveMask == "" {
+		return nil, errors.New("缺少目标区域 (--positive 或 --positive-mask)")
+	}
+	return f, nil
+}
+
+func parseArea(value string) (image.Rectangle, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("区域 %s 的格式错误，应为 x1,y1,x2,y2", value)
+	}
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("区域 %s 的格式错误，应为 x1,y1,x2,y2", value)
+		}
+		v[i] = n
+	}
+	return image.Rect(v[0], v[1], v[2], v[3]), nil
+}
+
+func calibrateColor(args []string) error {
+	f, err := parseCalibrateFlags(args)
+	if err != nil {
+		return err
+	}
+
+	var samples []detector.ColorSample
+	defer func() {
+		for _, s := range samples {
+			s.Img.Close()
+			s.Positive.Close()
+			s.Negative.Close()
+		}
+	}()
+	for _, file := range f.Files {
+		sample, err := loadColorSample(f, file)
+		if err != nil {
+			return err
+		}
+		samples = append(samples, sample)
+	}
+
+	result, err := detector.CalibrateColor(samples)
+	if err != nil {
+		return err
+	}
+
+	r := result.Range
+	fmt.Printf("[校准] 样本%d张 目标像素%d 背景像素%d\n", len(samples), result.Positives, result.Negatives)
+	fmt.Printf("[校准] 颜色范围: %v", r)
+	if r.Wraps() {
+		fmt.Print(" (跨越红色)")
+	}
+	fmt.Println()
+	fmt.Printf("[校准] 像素级 精确率%.1f%% 召回率%.1f%%\n", result.PixelPrecision*100, result.PixelRecall*100)
+	fmt.Printf("[校准] 面积阈值: %v 区域级 精确率%.1f%% 召回率%.1f%%\n", result.AreaThreshold, result.AreaPrecision*100, result.AreaRecall*100)
+
+	fmt.Println("\n可以直接粘贴到 preset.go 中:")
+	if !f.Region.Empty() {
+		fmt.Printf("\t%s = []int{%d, %d, %d, %d}\n", areaName(f.Name), f.Region.Min.X, f.Region.Min.Y, f.Region.Max.X, f.Region.Max.Y)
+	}
+	fmt.Printf("\t%s = detector.MustHSVRange(%s, %s) // 面积阈值: %v\n", f.Name, hsvLiteral(r.Min), hsvLiteral(r.Max), result.AreaThreshold)
+	return nil
+}
+
+// 与预设中的写法一致，例如: detector.HSV{H: 115, S: 25, V: 214}
+func hsvLiteral(c detector.HSV) string {
+	return fmt.Sprintf("detector.HSV{H: %d, S: %d, V: %d}", c.H, c.S, c.V)
+}
+
+// 预设中区域与颜色成对出现，例如 BossHealthColor 对应 BossHealthArea
+func areaName(name string) string {
+	return strings.TrimSuffix(name, "Color") + "Area"
+}
+
+// 读取截图与掩码，按 --region 截取后作为一个样本
+func loadColorSample(f *calibrateFlags, file string) (detector.ColorSample, error) {
+	// IMRead 读取的图片为BGR，与游戏截图的通道顺序一致，可以直接使用
+	img := gocv.IMRead(file, gocv.IMReadColor)
+	defer img.Close()
+	if img.Empty() {
+		return detector.ColorSample{}, fmt.Errorf("读取截图 %s 失败", file)
+	}
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+	region := bounds
+	if !f.Region.Empty() {
+		if !f.Region.In(bounds) {
+			return detector.ColorSample{}, fmt.Errorf("区域 %v 超出了截图 %s 的大小 %dx%d", f.Region, file, img.Cols(), img.Rows())
+		}
+		region = f.Region
+	}
+
+	sample := detector.ColorSample{Img: crop(img, region)}
+
+	var err error
+	if sample.Positive, err = loadMask(f.PositiveMask, f.Positive, file, img.Rows(), img.Cols(), region); err != nil {
+		sample.Img.Close()
+		return detector.ColorSample{}, err
+	}
+	if f.NegativeMask == "" && len(f.Negative) == 0 {
+		sample.Negative = gocv.NewMat()
+		return sample, nil
+	}
+	if sample.Negative, err = loadMask(f.NegativeMask, f.Negative, file, img.Rows(), img.Cols(), region); err != nil {
+		sample.Img.Close()
+		sample.Positive.Close()
+		return detector.ColorSample{}, err
+	}
+	return sample, nil
+}
+
+// 由掩码图片与区域生成掩码，两者都指定时取并集
+func loadMask(path string, rects []image.Rectangle, file string, rows, cols int, region image.Rectangle) (gocv.Mat, error) {
+	mask := gocv.Zeros(rows, cols, gocv.MatTypeCV8U)
+	defer mask.Close()
+
+	if path != "" {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, filepath.Base(file))
+		}
+		img := gocv.IMRead(path, gocv.IMReadGrayScale)
+		defer img.Close()
+		if img.Empty() {
+			return gocv.NewMat(), fmt.Errorf("读取掩码 %s 失败", path)
+		}
+		if img.Rows() != rows || img.Cols() != cols {
+			return gocv.NewMat(), fmt.Errorf("掩码 %s 的大小 %dx%d 与截图 %s 不一致", path, img.Cols(), img.Rows(), file)
+		}
+		img.CopyTo(&mask)
+	}
+	for _, rect := range rects {
+		rect = rect.Intersect(image.Rect(0, 0, cols, rows))
+		if rect.Empty() {
+			continue
+		}
+		part := mask.Region(rect)
+		part.SetTo(gocv.NewScalar(255, 0, 0, 0))
+		part.Close()
+	}
+	return crop(mask, region), nil
+}
+
+// 返回截取后的副本，调用层必须要关闭
+func crop(img gocv.Mat, rect image.Rectangle) gocv.Mat {
+	part := img.Region(rect)
+	defer part.Close()
+	return part.Clone()
+}
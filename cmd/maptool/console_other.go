@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// 非Windows系统仅用于回放，不调整命令行窗口
+
+func SetConsoleTitle(title string) {}
+
+func resizeCli() {}
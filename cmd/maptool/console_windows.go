@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/go-vgo/robotgo"
+	"github.com/tailscale/win"
+)
+
+var (
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procSetConsoleTitleW = kernel32.NewProc("SetConsoleTitleW")
+)
+
+func SetConsoleTitle(title string) {
+	titlePtr, err := syscall.UTF16PtrFromString(title)
+	if err != nil {
+		panic("获取窗口标题失败")
+	}
+	ret, _, _ := procSetConsoleTitleW.Call(uintptr(unsafe.Pointer(titlePtr)))
+	if ret == 0 {
+		panic("修改窗口标题失败")
+	}
+}
+
+func resizeCli() {
+	hwnd := robotgo.FindWindow(Title)
+	val := win.SetWindowPos(hwnd, win.HWND_TOP, 1280, 0, 1920-1280, 800, win.SWP_SHOWWINDOW)
+	if !val {
+		panic("调整当前窗口大小失败")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"star-map-tool/internal/config"
+	"star-map-tool/internal/game"
+	"strings"
+	"time"
+)
+
+// 命令行参数，指定 --map 后跳过交互式输入
+type Flags struct {
+	Config    string
+	Map       string
+	Mode      string
+	Times     int
+	Timeout   time.Duration
+	Interval  time.Duration
+	AutoStart bool     // 不等待F9，直接开始执行
+	Enable    []string // 额外启用的策略，格式: 地图-模式
+	Disable   []string // 停用的策略，格式: 地图-模式
+
+	Replay      string  // 回放录制的截图目录或视频，代替真实的游戏画面
+	ReplaySpeed float64 // 回放倍速
+	Record      string  // 录制每一轮的画面与键鼠操作，保存到此目录
+
+	FrameInterval time.Duration // 截图间隔，间隔内的识别共用同一张截图
+	DNNBackend    string        // 模型推理后端
+	DNNTarget     string        // 模型推理设备
+	MinTimeLeft   time.Duration // 副本倒计时低于此值时放弃本轮
+
+	set map[string]bool // 命令行中显式指定的参数
+}
+
+func parseFlags(args []string) (*Flags, error) {
+	f := &Flags{set: make(map[string]bool)}
+
+	fs := flag.NewFlagSet(Title, flag.ContinueOnError)
+	fs.StringVar(&f.Config, "config", ConfigPath, "配置文件路径")
+	fs.StringVar(&f.Map, "map", "", "目标地图，例如: 岩蛇巢穴 (指定后跳过交互式选择)")
+	fs.StringVar(&f.Mode, "mode", "", "地图模式，例如: 大师1 (地图只配置了一种模式时可省略)")
+	fs.IntVar(&f.Times, "times", 0, fmt.Sprintf("要进行的次数(1~%d)，默认使用配置文件中的值", config.MaxTimes))
+	fs.DurationVar(&f.Timeout, "timeout", 0, "单轮限时，例如: 17m，默认使用配置文件中的值")
+	fs.DurationVar(&f.Interval, "interval", 0, "下一轮开始前等待的时间，例如: 10s，默认使用配置文件中的值")
+	fs.BoolVar(&f.AutoStart, "autostart", false, "不等待F9，识别到游戏后直接开始执行，执行完毕后自动退出")
+	fs.Func("enable", "额外启用的地图，多个用逗号分隔，例如: 衰败深处-大师1,岩蛇巢穴-困难", func(value string) error {
+		f.Enable = append(f.Enable, splitList(value)...)
+		return nil
+	})
+	fs.Func("disable", "停用的地图，多个用逗号分隔，例如: 岩蛇巢穴-大师1", func(value string) error {
+		f.Disable = append(f.Disable, splitList(value)...)
+		return nil
+	})
+	fs.StringVar(&f.Replay, "replay", "", "回放录制的截图目录或视频文件(不操作键鼠，用于离线排查问题)，回放时自动开始")
+	fs.Float64Var(&f.ReplaySpeed, "replay-speed", 1, "回放倍速，目前只支持1 (策略中的等待按真实时间计算，其他倍速会使操作与画面错位)")
+	fs.StringVar(&f.Record, "record", "", "录制每一轮截取的画面与键鼠操作，保存到指定目录(每轮一个子目录)")
+	fs.DurationVar(&f.FrameInterval, "frame-interval", game.DefaultFrameInterval, "截图间隔，间隔内的识别共用同一张截图，0代表每次识别都单独截图")
+	fs.StringVar(&f.DNNBackend, "dnn-backend", "default", "模型推理后端: default、opencv、openvino、cuda、vulkan")
+	fs.StringVar(&f.DNNTarget, "dnn-target", "cpu", "模型推理设备: cpu、fp32、fp16(OpenCL)、cuda、cudafp16、vulkan")
+	fs.DurationVar(&f.MinTimeLeft, "min-time-left", 0, "游戏内的副本倒计时低于此值时放弃本轮，例如: 30s (需要从游戏中截取的数字模板)，0代表不检查")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if f.Replay != "" {
+		f.AutoStart = true // 回放时没有游戏窗口可供按键
+	}
+	if f.ReplaySpeed != 1 {
+		return nil, fmt.Errorf("--replay-speed 目前只支持1, 当前为%v", f.ReplaySpeed)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("无法识别的参数: %v", fs.Args())
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		f.set[fl.Name] = true
+	})
+	return f, nil
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// 未指定地图时需要用户手动输入
+func (f *Flags) Interactive() bool {
+	return f.Map == ""
+}
+
+// 根据命令行参数确定要执行的地图，命令行中的值优先于配置文件
+func (f *Flags) Resolve(defaults config.Defaults, options []config.Config) (config.Config, error) {
+	option, err := config.Find(options, f.Map, f.Mode)
+	if err != nil {
+		if f.Mode == "" {
+			return config.Config{}, err
+		}
+		option = defaults.New(f.Map, f.Mode) // 配置文件未配置的地图使用策略推荐的参数
+	}
+
+	if f.set["times"] {
+		option.Times = f.Times
+	}
+	if f.set["timeout"] {
+		option.Timeout = f.Timeout
+	}
+	if f.set["interval"] {
+		option.Interval = f.Interval
+	}
+	if err := config.Validate(option); err != nil {
+		return config.Config{}, err
+	}
+	return option, nil
+}
@@ -3,93 +3,137 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"os"
+	"star-map-tool/internal/config"
+	"star-map-tool/internal/detector"
 	"star-map-tool/internal/game"
 	"star-map-tool/internal/listener"
+	"star-map-tool/internal/pkg/paths"
 	"star-map-tool/internal/strategy"
-	"star-map-tool/internal/strategy/strategies/snake3"
-	"syscall"
-	"time"
-	"unsafe"
+	_ "star-map-tool/internal/strategy/strategies/clan2"
+	_ "star-map-tool/internal/strategy/strategies/clan3"
+	_ "star-map-tool/internal/strategy/strategies/robot2"
+	_ "star-map-tool/internal/strategy/strategies/sheep2"
+	_ "star-map-tool/internal/strategy/strategies/sheep3"
+	_ "star-map-tool/internal/strategy/strategies/snake2"
+	_ "star-map-tool/internal/strategy/strategies/snake3"
+	"strings"
 
-	"github.com/go-vgo/robotgo"
-	"github.com/tailscale/win"
+	"gocv.io/x/gocv"
 )
 
-var (
-	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+const Title string = "星痕共鸣-S2刷图工具"
 
-	procSetConsoleTitleW = kernel32.NewProc("SetConsoleTitleW")
-)
+const ConfigPath string = "configs/config.yaml"
 
-type Config struct {
-	Map         string
-	Mode        string
-	Times       int
-	Timeout     int
-	Interval    int
-	Description string
-}
+var interactive = true // 非交互模式下异常时不再等待用户按键
 
-const Title string = "星痕共鸣-S2刷图工具"
+func main() {
+	defer handlePanic()
 
-var Options []Config = []Config{
-	// {Map: "衰败深处", Mode: "大师1", Times: 999, Timeout: 12, Interval: 10, Description: "请让出治疗位，带上寂灭!"},
-	{Map: "岩蛇巢穴", Mode: "大师1", Times: 999, Timeout: 17, Interval: 10, Description: "请让出输出位，带上寂灭，带上野猪!"},
-	// {Map: "荒灵祭所", Mode: "大师1", Times: 999, Timeout: 9, Interval: 10, Description: "请让出奶位, 部分环节存在奶量压力!"},
-	// {Map: "衰败深处", Mode: "困难", Times: 999, Timeout: 10, Interval: 10, Description: "请让出奶位, 部分环节存在奶量压力!"},
-	// {Map: "岩蛇巢穴", Mode: "困难", Times: 999, Timeout: 12, Interval: 10, Description: "请让出奶位, 部分环节存在奶量压力!"},
-	// {Map: "荒灵祭所", Mode: "困难", Times: 999, Timeout: 9, Interval: 10, Description: "请让出奶位, 部分环节存在奶量压力!"},
-	// {Map: "无音之都", Mode: "困难", Times: 999, Timeout: 12, Interval: 10, Description: "请让出奶位, 部分环节存在奶量压力!"}, // 副本有BUG，吃不到球造成团灭
-	// {Map: "虫茧", Mode: "60", Times: 999, Timeout: 0, Interval: 1, Description: "需自行前往60虫茧外, 开启H后再启用副本方案"},
-}
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
 
-func RegisterStrategies(registry *strategy.Registry) {
-	// registry.Register(sheep3.NewStrategyImpl())
-	registry.Register(snake3.NewStrategyImpl())
-	// registry.Register(clan3.NewStrategyImpl())
+	// 颜色校准不需要游戏与配置文件，使用单独的参数
+	if command == "calibrate-color" {
+		interactive = false
+		if err := calibrateColor(args); errors.Is(err, flag.ErrHelp) {
+			return
+		} else if err != nil {
+			fmt.Println("[校准] ", err)
+			os.Exit(2)
+		}
+		return
+	}
 
-	// registry.Register(sheep2.NewStrategyImpl())
-	// registry.Register(snake2.NewStrategyImpl())
-	// registry.Register(clan2.NewStrategyImpl())
-	// registry.Register(robot2.NewStrategyImpl())
-	// registry.Register(farm60.NewFarm60Strategy())
-}
+	flags, err := parseFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fmt.Println("[启动器] ", err)
+		os.Exit(2)
+	}
+	interactive = command == "" && flags.Interactive()
+	detector.DefaultDNNConfig.Backend = gocv.ParseNetBackend(flags.DNNBackend)
+	detector.DefaultDNNConfig.Target = gocv.ParseNetTarget(flags.DNNTarget)
 
-func main() {
-	defer handlePanic()
+	registry := strategy.DefaultRegistry
+	defaults := getDefaults(registry)
+	options, err := defaults.Load(paths.Lookup(flags.Config))
+	if err != nil {
+		fatal("读取配置文件失败:\n%v", err)
+	}
+	enableStrategies(registry, options, flags)
 
+	switch command {
+	case "":
+		run(registry, defaults, options, flags)
+	case "list":
+		listStrategies(registry)
+	default:
+		fmt.Printf("[启动器] 未知的命令: %s (可用命令: list、calibrate-color)\n", command)
+		os.Exit(2)
+	}
+}
+
+func run(registry *strategy.Registry, defaults config.Defaults, options []config.Config, flags *Flags) {
 	SetConsoleTitle(Title)
 	showReadMe()
-	config := parseScan()
 
-	fmt.Printf("[启动器] 参数识别 [地图:%s] [模式:%s] [次数:%d] [单轮限时分钟数:%d] [下一轮开始前等待秒数:%d]\n",
+	var config config.Config
+	var err error
+	if flags.Interactive() {
+		config = parseScan(getEnabledOptions(registry, defaults, options))
+	} else {
+		// 命令行中可以使用别名，先转换为注册的地图名称与模式
+		s, err := registry.GetStrategy(flags.Map, flags.Mode)
+		if err != nil {
+			fatal("命令行参数错误: %v", err)
+		}
+		flags.Map, flags.Mode = s.GetName(), s.GetMode()
+		if config, err = flags.Resolve(defaults, options); err != nil {
+			fatal("命令行参数错误: %v", err)
+		}
+	}
+
+	// 游戏策略选择
+	selector := strategy.NewSelector(registry)
+	selected, err := selector.Select(config.Map, config.Mode)
+	if err != nil {
+		fatal("%v", err)
+	}
+	executor := strategy.NewExecutor(selector)
+
+	fmt.Printf("[启动器] 参数识别 [地图:%s] [模式:%s] [次数:%d] [单轮限时:%v] [下一轮开始前等待:%v]\n",
 		config.Map, config.Mode, config.Times, config.Timeout, config.Interval)
 	resizeCli()
-	showMapDescripion(config)
+	showMapDescripion(selected, config)
 
 	// 游戏窗体 或 进程
-	game, err := game.NewGame("Star.exe", "星痕共鸣")
+	game, err := newGame(flags)
 	if err != nil {
 		fmt.Println("[启动器] ", err)
 		return
 	}
-	if ok := game.Initialize(); !ok {
-		return
+	game.SetFrameInterval(flags.FrameInterval)
+	if flags.Record != "" {
+		game.Record(flags.Record)
+		fmt.Printf("[启动器] 已开启录制, 每一轮的画面与键鼠操作将保存到 %s\n", flags.Record)
 	}
 
-	// 游戏策略选择
-	registry := strategy.NewRegistry()
-	RegisterStrategies(registry)
-
-	selector := strategy.NewSelector(registry)
-	executor := strategy.NewExecutor(selector)
-
 	// 特殊按键监听器
 	ctx, _ := context.WithCancel(context.Background())
 	listener := listener.New()
 	go listener.Start(ctx)
+	if flags.AutoStart {
+		go listener.Trigger()
+	}
 
 	for {
 		// 等待F9
@@ -101,42 +145,143 @@ func main() {
 		executor.Execute(&strategy.ExecutionConfig{
 			Game:     game,
 			Times:    config.Times,
-			Timeout:  time.Duration(config.Timeout) * time.Minute,
-			Interval: time.Duration(config.Interval) * time.Second,
+			Timeout:  config.Timeout,
+			Interval: config.Interval,
 			Listener: listener,
-		}, *selector.Select(config.Map, config.Mode), data)
+
+			MinTimeLeft: flags.MinTimeLeft,
+		}, selected, data)
+
+		if flags.AutoStart {
+			log.Println("[启动器] 已完成全部轮次, 即将退出程序")
+			listener.Release()
+		}
+	}
+}
+
+// 指定 --replay 时使用录制的画面，否则连接游戏进程
+func newGame(flags *Flags) (*game.Game, error) {
+	if flags.Replay == "" {
+		g, err := game.NewGame("Star.exe", "星痕共鸣")
+		if err != nil {
+			return nil, err
+		}
+		if ok := g.Initialize(); !ok {
+			return nil, errors.New("游戏初始化失败")
+		}
+		return g, nil
+	}
+
+	source, err := game.NewReplaySource(flags.Replay, game.NewWallClock(flags.ReplaySpeed))
+	if err != nil {
+		return nil, err
+	}
+	w, h := source.Size()
+	fmt.Printf("[启动器] 正在回放 %s (%dx%d, %.1f倍速)\n", flags.Replay, w, h, flags.ReplaySpeed)
+	return game.NewReplayGame(source), nil
+}
+
+// 按配置文件启用策略，命令行中的 --enable/--disable 优先
+func enableStrategies(registry *strategy.Registry, options []config.Config, flags *Flags) {
+	for _, o := range options {
+		if err := registry.SetEnabled(o.Map, o.Mode, o.Enable); err != nil {
+			fmt.Printf("[启动器] 配置文件中的%v, 已忽略\n", err)
+		}
+	}
+
+	toggle := func(keys []string, enabled bool) {
+		for _, key := range keys {
+			name, mode, _ := strings.Cut(key, "-") // 别名不需要模式
+			if err := registry.SetEnabled(name, mode, enabled); err != nil {
+				fmt.Printf("[启动器] 命令行参数中的%v, 已忽略 (格式: 地图-模式 或 别名)\n", err)
+			}
+		}
+	}
+	toggle(flags.Enable, true)
+	toggle(flags.Disable, false)
+}
+
+// 由已注册策略的元数据生成内置参数
+func getDefaults(registry *strategy.Registry) config.Defaults {
+	var defaults config.Defaults
+	for _, s := range registry.GetStrategyList() {
+		meta := s.GetMetadata()
+		defaults = append(defaults, config.Config{
+			Map:         s.GetName(),
+			Mode:        s.GetMode(),
+			Times:       config.DefaultTimes,
+			Timeout:     meta.Timeout,
+			Interval:    meta.Interval,
+			Description: meta.Description,
+			Enable:      meta.Enable,
+		})
+	}
+	return defaults
+}
+
+// 已启用的地图，配置文件中的顺序优先，其余按注册顺序排列
+func getEnabledOptions(registry *strategy.Registry, defaults config.Defaults, options []config.Config) []config.Config {
+	var list []config.Config
+	added := make(map[string]bool)
+	for _, o := range options {
+		if registry.IsEnabled(o.Map, o.Mode) {
+			list = append(list, o)
+			added[o.Map+"-"+o.Mode] = true
+		}
+	}
+	for _, s := range registry.GetStrategyList() {
+		name, mode := s.GetName(), s.GetMode()
+		if registry.IsEnabled(name, mode) && !added[name+"-"+mode] {
+			list = append(list, defaults.New(name, mode))
+		}
 	}
+	return list
 }
 
-func parseScan() Config {
+func listStrategies(registry *strategy.Registry) {
+	fmt.Println("已支持的地图: ")
+	for i, s := range registry.GetStrategyList() {
+		state := "未启用"
+		if registry.IsEnabled(s.GetName(), s.GetMode()) {
+			state = "已启用"
+		}
+		fmt.Printf("%d. %s (%s) [%s] 别名: %s\n", i+1, s.GetName(), s.GetMode(), state, strings.Join(s.GetMetadata().Aliases, ", "))
+	}
+}
+
+func parseScan(options []config.Config) config.Config {
 	var index int
 	var times int
 
+	if len(options) == 0 {
+		panic("没有已启用的地图, 请检查配置文件或 --enable 参数")
+	}
+
 	fmt.Println("当前支持的地图: ")
-	for i, o := range Options {
+	for i, o := range options {
 		fmt.Printf("%d. %s (%s)\n", i+1, o.Map, o.Mode)
 	}
 	for {
 		fmt.Print("请选择目标地图(按下回车确认): ")
 		fmt.Scanln(&index)
-		if index <= 0 || index > len(Options) {
+		if index <= 0 || index > len(options) {
 			fmt.Println("尚未支持目标地图")
 			continue
 		} else {
 			break
 		}
 	}
-	option := Options[index-1]
+	option := options[index-1]
 
 	for {
-		fmt.Print("要进行的次数(默认:999): ")
+		fmt.Printf("要进行的次数(默认:%d): ", option.Times)
 		fmt.Scanln(&times)
 		if times == 0 {
 			times = option.Times
 			break
 		}
-		if times < 1 || times > 999 {
-			fmt.Println("请输入1~999范围内的次数")
+		if times < 1 || times > config.MaxTimes {
+			fmt.Printf("请输入1~%d范围内的次数\n", config.MaxTimes)
 			continue
 		} else {
 			break
@@ -167,32 +312,28 @@ func showReadMe() {
 	fmt.Printf("\n\n")
 }
 
-func showMapDescripion(config Config) {
+func showMapDescripion(selected strategy.Strategy, config config.Config) {
+	roles := "无要求"
+	if meta := selected.GetMetadata(); len(meta.Roles) > 0 {
+		roles = meta.Roles
+	}
 	description := "无要求"
 	if len(config.Description) > 0 {
 		description = config.Description
 	}
 
+	fmt.Printf("本地图职业要求: %s\n", roles)
 	fmt.Printf("本地图需注意: %s\n\n", description)
 }
 
-func SetConsoleTitle(title string) {
-	titlePtr, err := syscall.UTF16PtrFromString(title)
-	if err != nil {
-		panic("获取窗口标题失败")
-	}
-	ret, _, _ := procSetConsoleTitleW.Call(uintptr(unsafe.Pointer(titlePtr)))
-	if ret == 0 {
-		panic("修改窗口标题失败")
-	}
-}
-
-func resizeCli() {
-	hwnd := robotgo.FindWindow(Title)
-	val := win.SetWindowPos(hwnd, win.HWND_TOP, 1280, 0, 1920-1280, 800, win.SWP_SHOWWINDOW)
-	if !val {
-		panic("调整当前窗口大小失败")
+// 输出错误信息后退出，交互模式下等待用户确认
+func fatal(format string, args ...any) {
+	fmt.Printf("[启动器] "+format+"\n", args...)
+	if interactive {
+		fmt.Print("按任意键退出程序...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
 	}
+	os.Exit(1)
 }
 
 func handlePanic() {
@@ -200,6 +341,9 @@ func handlePanic() {
 		game.ReleaseAllKey()
 		fmt.Println("\n============ 异常捕获 ===============")
 		fmt.Printf("异常信息: %v\n", r)
+		if !interactive {
+			os.Exit(1)
+		}
 
 		fmt.Print("按任意键退出程序...")
 		bufio.NewReader(os.Stdin).ReadString('\n')
@@ -3,10 +3,12 @@ module star-map-tool
 go 1.25.4
 
 require (
+	github.com/go-vgo/robotgo v0.110.8
 	github.com/robotn/gohook v0.42.2
 	github.com/tailscale/win v0.0.0-20250627215312-f4da2b8ee071
 	gocv.io/x/gocv v0.42.0
 	golang.org/x/text v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -14,7 +16,6 @@ require (
 	github.com/ebitengine/purego v0.9.1 // indirect
 	github.com/gen2brain/shm v0.1.1 // indirect
 	github.com/go-ole/go-ole v1.3.0 // indirect
-	github.com/go-vgo/robotgo v0.110.8 // indirect
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
 	github.com/lufia/plan9stats v0.0.0-20251013123823-9fd1530e3ec3 // indirect
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 单个地图的运行参数
+type Config struct {
+	Map         string
+	Mode        string
+	Times       int           // 要执行的次数
+	Timeout     time.Duration // 单轮限时，0代表不限时
+	Interval    time.Duration // 下一轮开始前的等待时间
+	Description string        // 刷本建议（职业要求等）
+	Enable      bool          // 是否启用此地图的策略
+}
+
+// 配置文件中的错误，附带出错的行号
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Msg)
+}
+
+const (
+	MaxTimes        int           = 999
+	DefaultTimes    int           = 999
+	DefaultInterval time.Duration = 10 * time.Second
+)
+
+// 内置的地图参数（由已注册策略的元数据生成），配置文件中缺省的字段以此为准
+type Defaults []Config
+
+// 配置文件不存在或为空时启用的地图
+func (d Defaults) Enabled() []Config {
+	var list []Config
+	for _, c := range d {
+		if c.Enable {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// 返回指定地图的参数，未内置的地图使用通用默认值
+func (d Defaults) New(name string, mode string) Config {
+	return d.fill(Config{Map: name, Mode: mode}, map[string]bool{})
+}
+
+// 在地图列表中查找，mode 为空时要求该地图只配置了一种模式
+func Find(options []Config, name string, mode string) (Config, error) {
+	var matched []Config
+	for _, c := range options {
+		if c.Map == name && (mode == "" || c.Mode == mode) {
+			matched = append(matched, c)
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		if mode == "" {
+			return Config{}, fmt.Errorf("未配置地图 %s", name)
+		}
+		return Config{}, fmt.Errorf("未配置地图 %s(%s)", name, mode)
+	case 1:
+		return matched[0], nil
+	default:
+		modes := make([]string, 0, len(matched))
+		for _, c := range matched {
+			modes = append(modes, c.Mode)
+		}
+		return Config{}, fmt.Errorf("地图 %s 配置了多种模式(%s), 请指定模式", name, strings.Join(modes, "、"))
+	}
+}
+
+// 读取配置文件，文件不存在或没有配置任何地图时返回默认启用的地图
+func (d Defaults) Load(path string) ([]Config, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d.Enabled(), nil
+	}
+	if err != nil {
+		return nil, &Error{File: path, Msg: err.Error()}
+	}
+	return d.Parse(path, content)
+}
+
+// 解析配置内容，name 仅用于错误提示
+func (d Defaults) Parse(name string, content []byte) ([]Config, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, &Error{File: name, Msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	if len(root.Content) == 0 {
+		return d.Enabled(), nil // 空文件
+	}
+
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, &Error{File: name, Line: doc.Line, Msg: "配置文件顶层必须是键值对"}
+	}
+
+	var options *yaml.Node
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		switch key.Value {
+		case "options":
+			options = value
+		default:
+			return nil, &Error{File: name, Line: key.Line, Msg: fmt.Sprintf("未知的配置项 %q", key.Value)}
+		}
+	}
+	if options == nil || options.Tag == "!!null" {
+		return d.Enabled(), nil
+	}
+	if options.Kind != yaml.SequenceNode {
+		return nil, &Error{File: name, Line: options.Line, Msg: "options 必须是列表"}
+	}
+	if len(options.Content) == 0 {
+		return d.Enabled(), nil
+	}
+
+	var errs []error
+	var list []Config
+	lines := make(map[string]int)
+	for _, item := range options.Content {
+		c, err := d.parseOption(name, item)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		key := c.Map + "-" + c.Mode
+		if line, ok := lines[key]; ok {
+			errs = append(errs, &Error{File: name, Line: item.Line, Msg: fmt.Sprintf("地图 %s(%s) 与第%d行重复", c.Map, c.Mode, line)})
+			continue
+		}
+		lines[key] = item.Line
+		list = append(list, c)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return list, nil
+}
+
+func (d Defaults) parseOption(name string, node *yaml.Node) (Config, error) {
+	var c Config
+	if node.Kind != yaml.MappingNode {
+		return c, &Error{File: name, Line: node.Line, Msg: "地图配置必须是键值对"}
+	}
+
+	fail := func(n *yaml.Node, format string, args ...any) (Config, error) {
+		return c, &Error{File: name, Line: n.Line, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if seen[key.Value] {
+			return fail(key, "重复的字段 %q", key.Value)
+		}
+		seen[key.Value] = true
+		if value.Kind != yaml.ScalarNode {
+			return fail(value, "字段 %s 必须是单个值", key.Value)
+		}
+
+		switch key.Value {
+		case "map":
+			c.Map = strings.TrimSpace(value.Value)
+		case "mode":
+			c.Mode = strings.TrimSpace(value.Value)
+		case "description":
+			c.Description = value.Value
+		case "enable":
+			if err := value.Decode(&c.Enable); err != nil {
+				return fail(value, "enable 必须是 true 或 false: %q", value.Value)
+			}
+		case "times":
+			times, err := strconv.Atoi(value.Value)
+			if err != nil {
+				return fail(value, "times 必须是整数: %q", value.Value)
+			}
+			c.Times = times
+		case "timeout":
+			d, err := parseDuration(value.Value, time.Minute)
+			if err != nil {
+				return fail(value, "timeout 格式错误(例如: 17 或 17m): %q", value.Value)
+			}
+			c.Timeout = d
+		case "interval":
+			d, err := parseDuration(value.Value, time.Second)
+			if err != nil {
+				return fail(value, "interval 格式错误(例如: 10 或 10s): %q", value.Value)
+			}
+			c.Interval = d
+		default:
+			return fail(key, "未知的字段 %q", key.Value)
+		}
+	}
+
+	if c.Map == "" {
+		return fail(node, "缺少地图名称 map")
+	}
+	if c.Mode == "" {
+		return fail(node, "地图 %s 缺少模式 mode", c.Map)
+	}
+	c = d.fill(c, seen)
+	if err := Validate(c); err != nil {
+		return fail(node, "%s", err.Error())
+	}
+	return c, nil
+}
+
+// 校验单个地图配置
+func Validate(c Config) error {
+	if c.Map == "" || c.Mode == "" {
+		return errors.New("地图名称与模式不能为空")
+	}
+	if c.Times < 1 || c.Times > MaxTimes {
+		return fmt.Errorf("地图 %s(%s) 的执行次数必须在1~%d范围内, 当前为%d", c.Map, c.Mode, MaxTimes, c.Times)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("地图 %s(%s) 的单轮限时不能为负数", c.Map, c.Mode)
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("地图 %s(%s) 的等待时间不能为负数", c.Map, c.Mode)
+	}
+	return nil
+}
+
+// 未填写的字段使用内置参数补齐
+func (d Defaults) fill(c Config, seen map[string]bool) Config {
+	base := Config{Times: DefaultTimes, Interval: DefaultInterval}
+	for _, b := range d {
+		if b.Map == c.Map && b.Mode == c.Mode {
+			base = b
+			break
+		}
+	}
+
+	if !seen["times"] {
+		c.Times = base.Times
+	}
+	if !seen["timeout"] {
+		c.Timeout = base.Timeout
+	}
+	if !seen["interval"] {
+		c.Interval = base.Interval
+	}
+	if !seen["description"] {
+		c.Description = base.Description
+	}
+	if !seen["enable"] {
+		c.Enable = true
+	}
+	return c
+}
+
+// 纯数字按 unit 计算，其余按 time.ParseDuration 解析
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * unit, nil
+	}
+	return time.ParseDuration(value)
+}
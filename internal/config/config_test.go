@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var testDefaults = Defaults{
+	{Map: "sheep", Mode: "normal", Times: 10, Timeout: 17 * time.Minute, Interval: 5 * time.Second, Description: "内置说明", Enable: true},
+	{Map: "clan", Mode: "hard", Times: 20, Enable: false},
+}
+
+func TestParse(t *testing.T) {
+	content := `
+options:
+  - map: sheep
+    mode: normal
+    timeout: 20
+  - map: clan
+    mode: hard
+    times: 3
+    interval: 30s
+    enable: false
+  - map: robot
+    mode: normal
+`
+	list, err := testDefaults.Parse("config.yaml", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Config{
+		{Map: "sheep", Mode: "normal", Times: 10, Timeout: 20 * time.Minute, Interval: 5 * time.Second, Description: "内置说明", Enable: true},
+		{Map: "clan", Mode: "hard", Times: 3, Interval: 30 * time.Second, Enable: false},
+		{Map: "robot", Mode: "normal", Times: DefaultTimes, Interval: DefaultInterval, Enable: true},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("地图数 = %d, 期望 %d: %+v", len(list), len(want), list)
+	}
+	for i := range want {
+		if list[i] != want[i] {
+			t.Errorf("第%d个地图 = %+v, 期望 %+v", i+1, list[i], want[i])
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, content := range []string{"", "options:\n", "options: []\n"} {
+		list, err := testDefaults.Parse("config.yaml", []byte(content))
+		if err != nil {
+			t.Fatalf("%q: %v", content, err)
+		}
+		if len(list) != 1 || list[0].Map != "sheep" {
+			t.Errorf("%q: 结果 = %+v, 期望默认启用的地图", content, list)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		line    int
+	}{
+		{"未知的配置项", "options: []\nmaps: []\n", 2},
+		{"未知的字段", "options:\n  - map: sheep\n    mode: normal\n    speed: 2\n", 4},
+		{"时长格式错误", "options:\n  - map: sheep\n    mode: normal\n    timeout: 17x\n", 4},
+		{"次数超出范围", "options:\n  - map: sheep\n    mode: normal\n    times: 1000\n", 2},
+		{"缺少模式", "options:\n  - map: sheep\n", 2},
+		{"重复的地图", "options:\n  - map: sheep\n    mode: normal\n  - map: sheep\n    mode: normal\n", 4},
+	}
+	for _, c := range cases {
+		_, err := testDefaults.Parse("config.yaml", []byte(c.content))
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s: 错误 = %v, 期望 *Error", c.name, err)
+			continue
+		}
+		if e.File != "config.yaml" || e.Line != c.line {
+			t.Errorf("%s: 错误位置 = %s:%d, 期望 config.yaml:%d (%v)", c.name, e.File, e.Line, c.line, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ok := Config{Map: "sheep", Mode: "normal", Times: 1}
+	if err := Validate(ok); err != nil {
+		t.Errorf("Validate(%+v) = %v", ok, err)
+	}
+	for _, c := range []Config{
+		{Mode: "normal", Times: 1},
+		{Map: "sheep", Mode: "normal", Times: 0},
+		{Map: "sheep", Mode: "normal", Times: MaxTimes + 1},
+		{Map: "sheep", Mode: "normal", Times: 1, Timeout: -time.Second},
+		{Map: "sheep", Mode: "normal", Times: 1, Interval: -time.Second},
+	} {
+		if err := Validate(c); err == nil {
+			t.Errorf("Validate(%+v) 未返回错误", c)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package detector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"gocv.io/x/gocv"
+)
+
+// 颜色校准的样本
+type ColorSample struct {
+	Img      gocv.Mat // 截图，与游戏截图一样为BGR (gocv.IMRead 读取的图片可以直接使用)
+	Positive gocv.Mat // 目标像素的掩码，非0代表目标，与 Img 大小一致
+	Negative gocv.Mat // 背景像素的掩码，为空时 Positive 以外的像素都视为背景
+}
+
+// 颜色校准的结果
+type ColorCalibration struct {
+	Range          HSVRange
+	AreaThreshold  float32 // 与颜色识别的 scoreThreshold 含义一致，面积不大于此值的区域会被忽略
+	Positives      int     // 目标像素数
+	Negatives      int     // 背景像素数
+	PixelPrecision float64 // 像素级精确率: 范围内的像素中目标像素的比例
+	PixelRecall    float64 // 像素级召回率: 目标像素中在范围内的比例
+	AreaPrecision  float64 // 区域级精确率: 按面积阈值过滤后，识别出的区域中目标区域的比例
+	AreaRecall     float64 // 区域级召回率: 识别出的目标区域中保留下来的比例
+}
+
+// 依次尝试的离群值比例，目标中混入的少量背景像素会使颜色范围过宽
+var calibrateTrims = []float64{0, 0.005, 0.01, 0.02, 0.05}
+
+// 根据样本中的目标与背景像素推荐颜色范围与面积阈值
+func CalibrateColor(samples []ColorSample) (ColorCalibration, error) {
+	var pos, neg []HSV
+	for i, sample := range samples {
+		p, n, err := samplePixels(sample)
+		if err != nil {
+			return ColorCalibration{}, fmt.Errorf("样本%d: %w", i+1, err)
+		}
+		pos, neg = append(pos, p...), append(neg, n...)
+	}
+	if len(pos) == 0 {
+		return ColorCalibration{}, errors.New("没有目标像素，请检查目标区域或掩码")
+	}
+
+	var best ColorCalibration
+	bestScore := -1.0
+	for _, trim := range calibrateTrims {
+		r := proposeHSVRange(pos, trim)
+		precision, recall := evaluateHSVRange(r, pos, neg)
+		if score := f1(precision, recall); score > bestScore {
+			bestScore = score
+			best = ColorCalibration{Range: r, PixelPrecision: precision, PixelRecall: recall}
+		}
+	}
+	best.Positives, best.Negatives = len(pos), len(neg)
+	best.AreaThreshold, best.AreaPrecision, best.AreaRecall = calibrateArea(samples, best.Range)
+	return best, nil
+}
+
+// 按掩码将样本的像素分为目标与背景
+func samplePixels(sample ColorSample) ([]HSV, []HSV, error) {
+	img := sample.Img
+	if img.Empty() {
+		return nil, nil, errors.New("截图为空")
+	}
+	for _, mask := range []gocv.Mat{sample.Positive, sample.Negative} {
+		if !mask.Empty() && (mask.Rows() != img.Rows() || mask.Cols() != img.Cols()) {
+			return nil, nil, fmt.Errorf("掩码大小 %dx%d 与截图 %dx%d 不一致", mask.Cols(), mask.Rows(), img.Cols(), img.Rows())
+		}
+	}
+	if sample.Positive.Empty() {
+		return nil, nil, errors.New("缺少目标掩码")
+	}
+
+	hsv := gocv.NewMat()
+	defer hsv.Close()
+	toHSV(img, &hsv)
+	data, err := hsv.DataPtrUint8()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pos, neg []HSV
+	cols := hsv.Cols()
+	for y := range hsv.Rows() {
+		for x := range cols {
+			i := (y*cols + x) * 3
+			c := HSV{int(data[i]), int(data[i+1]), int(data[i+2])}
+			if sample.Positive.GetUCharAt(y, x) > 0 {
+				pos = append(pos, c)
+			} else if sample.Negative.Empty() || sample.Negative.GetUCharAt(y, x) > 0 {
+				neg = append(neg, c)
+			}
+		}
+	}
+	return pos, neg, nil
+}
+
+// 去掉 trim 比例的离群值后，取包含全部目标像素的最小范围
+func proposeHSVRange(pos []HSV, trim float64) HSVRange {
+	var hue [MaxHue + 1]int
+	var sat [MaxSaturation + 1]int
+	var val [MaxValue + 1]int
+	for _, c := range pos {
+		hue[c.H]++
+		sat[c.S]++
+		val[c.V]++
+	}
+
+	skip := int(float64(len(pos)) * trim)
+	minH, maxH := hueRange(hue, skip)
+	minS, maxS := percentileRange(sat[:], skip)
+	minV, maxV := percentileRange(val[:], skip)
+	return HSVRange{Min: HSV{minH, minS, minV}, Max: HSV{maxH, maxS, maxV}}
+}
+
+// 两端各去掉不超过 skip 个像素后的取值范围
+func percentileRange(hist []int, skip int) (int, int) {
+	lo, hi := 0, len(hist)-1
+	for acc := 0; lo < hi; lo++ {
+		if acc += hist[lo]; acc > skip {
+			break
+		}
+	}
+	for acc := 0; hi > lo; hi-- {
+		if acc += hist[hi]; acc > skip {
+			break
+		}
+	}
+	return lo, hi
+}
+
+// 色相首尾相接，去掉像素最少的色相(合计不超过 skip 个)后，取最长的空白以外的部分
+// 目标是红色时返回的下限会大于上限，即跨越红色的范围
+func hueRange(hist [MaxHue + 1]int, skip int) (int, int) {
+	const n = MaxHue + 1
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return hist[a] - hist[b]
+	})
+	for _, h := range order {
+		if hist[h] == 0 {
+			continue
+		}
+		if hist[h] > skip {
+			break
+		}
+		skip -= hist[h]
+		hist[h] = 0
+	}
+
+	gapStart, gapLen := 0, 0
+	for start := range n {
+		if hist[start] != 0 || hist[(start+n-1)%n] == 0 { // 只从空白的起点开始计算
+			continue
+		}
+		l := 0
+		for l < n && hist[(start+l)%n] == 0 {
+			l++
+		}
+		if l > gapLen {
+			gapStart, gapLen = start, l
+		}
+	}
+	if gapLen == 0 {
+		return 0, MaxHue
+	}
+	return (gapStart + gapLen) % n, (gapStart + n - 1) % n
+}
+
+func evaluateHSVRange(r HSVRange, pos, neg []HSV) (float64, float64) {
+	tp, fp := 0, 0
+	for _, c := range pos {
+		if r.Contains(c) {
+			tp++
+		}
+	}
+	for _, c := range neg {
+		if r.Contains(c) {
+			fp++
+		}
+	}
+	return ratio(tp, tp+fp), ratio(tp, len(pos))
+}
+
+// 识别出的区域，一半以上是目标像素时视为目标区域
+type colorBlob struct {
+	area     float64
+	positive bool
+}
+
+// 按颜色范围识别各个样本，选出最能区分目标区域与背景区域的面积阈值
+func calibrateArea(samples []ColorSample, r HSVRange) (float32, float64, float64) {
+	var blobs []colorBlob
+	for _, sample := range samples {
+		blobs = append(blobs, findColorBlobs(sample, r)...)
+	}
+
+	total := 0
+	thresholds := []float64{0}
+	for _, b := range blobs {
+		if b.positive {
+			total++
+		}
+		thresholds = append(thresholds, b.area)
+	}
+	slices.Sort(thresholds)
+
+	var best, bestPrecision, bestRecall float64
+	bestScore := -1.0
+	for _, t := range thresholds {
+		tp, fp := 0, 0
+		for _, b := range blobs {
+			if b.area <= t {
+				continue
+			}
+			if b.positive {
+				tp++
+			} else {
+				fp++
+			}
+		}
+		precision, recall := ratio(tp, tp+fp), ratio(tp, total)
+		if score := f1(precision, recall); score > bestScore {
+			best, bestScore, bestPrecision, bestRecall = t, score, precision, recall
+		}
+	}
+
+	// 阈值取在过滤掉的区域与保留的最小目标区域之间，留出余量
+	smallest := math.Inf(1)
+	for _, b := range blobs {
+		if b.positive && b.area > best {
+			smallest = min(smallest, b.area)
+		}
+	}
+	if !math.IsInf(smallest, 1) {
+		best = math.Floor((best + smallest) / 2)
+	}
+	return float32(best), bestPrecision, bestRecall
+}
+
+func findColorBlobs(sample ColorSample, r HSVRange) []colorBlob {
+	hsv := gocv.NewMat()
+	mask := gocv.NewMat()
+	defer hsv.Close()
+	defer mask.Close()
+	toHSV(sample.Img, &hsv)
+	r.mask(hsv, &mask)
+
+	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	var blobs []colorBlob
+	for i := range contours.Size() {
+		contour := contours.At(i)
+		area := gocv.ContourArea(contour)
+
+		region := sample.Positive.Region(gocv.BoundingRect(contour))
+		positive := float64(gocv.CountNonZero(region)) > area/2
+		region.Close()
+		blobs = append(blobs, colorBlob{area: area, positive: positive})
+	}
+	return blobs
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
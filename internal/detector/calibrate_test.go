@@ -0,0 +1,131 @@
+package detector
+
+import (
+	"image"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// 样本中的一个区域，target 为 true 时计入目标掩码
+type calibrateRegion struct {
+	rect   image.Rectangle
+	bgr    gocv.Scalar
+	target bool
+}
+
+// 灰色背景的 40x40 样本，依次填充各个区域的BGR颜色
+func calibrateSample(regions ...calibrateRegion) ColorSample {
+	img := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(100, 100, 100, 0), 40, 40, gocv.MatTypeCV8UC3)
+	positive := gocv.Zeros(40, 40, gocv.MatTypeCV8U)
+	for _, r := range regions {
+		region := img.Region(r.rect)
+		region.SetTo(r.bgr)
+		region.Close()
+		if r.target {
+			fillRect(positive, r.rect)
+		}
+	}
+	return ColorSample{Img: img, Positive: positive, Negative: gocv.NewMat()}
+}
+
+func TestCalibrateColor(t *testing.T) {
+	green := gocv.NewScalar(0, 200, 0, 0) // HSV(60, 255, 200)
+	cases := []struct {
+		name     string
+		sample   ColorSample
+		want     HSVRange
+		wraps    bool
+		minArea  float32 // 面积阈值应在 [minArea, maxArea) 之间，面积不大于阈值的区域会被过滤
+		maxArea  float32
+		contains []HSV
+	}{
+		{
+			name: "绿色",
+			sample: calibrateSample(
+				calibrateRegion{image.Rect(5, 5, 15, 15), green, true},
+				calibrateRegion{image.Rect(20, 20, 30, 30), gocv.NewScalar(0, 150, 0, 0), true}, // HSV(60, 255, 150)
+			),
+			want:    HSVRange{Min: HSV{H: 60, S: 255, V: 150}, Max: HSV{H: 60, S: 255, V: 200}},
+			minArea: 0,
+			maxArea: 81,
+		},
+		{
+			name: "跨越红色",
+			sample: calibrateSample(
+				calibrateRegion{image.Rect(2, 2, 12, 12), gocv.NewScalar(0, 0, 200, 0), true},   // H 0
+				calibrateRegion{image.Rect(14, 2, 24, 12), gocv.NewScalar(40, 0, 200, 0), true}, // H 174
+				calibrateRegion{image.Rect(26, 2, 36, 12), gocv.NewScalar(0, 40, 200, 0), true}, // H 6
+			),
+			want:     HSVRange{Min: HSV{H: 174, S: 255, V: 200}, Max: HSV{H: 6, S: 255, V: 200}},
+			wraps:    true,
+			minArea:  0,
+			maxArea:  81,
+			contains: []HSV{{H: 0, S: 255, V: 200}, {H: 178, S: 255, V: 200}, {H: 3, S: 255, V: 200}},
+		},
+		{
+			name: "过滤噪点",
+			sample: calibrateSample(
+				calibrateRegion{image.Rect(5, 5, 15, 15), green, true},  // 面积81
+				calibrateRegion{image.Rect(25, 5, 27, 7), green, false}, // 面积1
+				calibrateRegion{image.Rect(25, 20, 27, 22), green, false},
+				calibrateRegion{image.Rect(5, 30, 7, 32), green, false},
+			),
+			want:    HSVRange{Min: HSV{H: 60, S: 255, V: 200}, Max: HSV{H: 60, S: 255, V: 200}},
+			minArea: 1,
+			maxArea: 81,
+		},
+	}
+	for _, c := range cases {
+		result, err := CalibrateColor([]ColorSample{c.sample})
+		c.sample.Img.Close()
+		c.sample.Positive.Close()
+		c.sample.Negative.Close()
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		if result.Range != c.want || result.Range.Wraps() != c.wraps {
+			t.Errorf("%s: 颜色范围 = %v, 期望 %v", c.name, result.Range, c.want)
+		}
+		for _, hsv := range c.contains {
+			if !result.Range.Contains(hsv) {
+				t.Errorf("%s: 颜色范围 %v 应包含 %v", c.name, result.Range, hsv)
+			}
+		}
+		if result.Range.Contains(HSV{H: 0, S: 0, V: 100}) {
+			t.Errorf("%s: 颜色范围 %v 不应包含背景", c.name, result.Range)
+		}
+		if result.AreaThreshold < c.minArea || result.AreaThreshold >= c.maxArea {
+			t.Errorf("%s: 面积阈值 = %v, 期望在 [%v, %v) 之间", c.name, result.AreaThreshold, c.minArea, c.maxArea)
+		}
+		if result.AreaPrecision != 1 || result.AreaRecall != 1 {
+			t.Errorf("%s: 区域级 精确率%v 召回率%v, 期望都为1", c.name, result.AreaPrecision, result.AreaRecall)
+		}
+	}
+}
+
+func TestHueRange(t *testing.T) {
+	cases := []struct {
+		name     string
+		hues     map[int]int // 色相: 像素数
+		skip     int
+		min, max int
+	}{
+		{"单一色相", map[int]int{60: 10}, 0, 60, 60},
+		{"连续范围", map[int]int{100: 5, 110: 5, 120: 5}, 0, 100, 120},
+		{"跨越红色", map[int]int{0: 5, 6: 5, 174: 5}, 0, 174, 6},
+		{"去掉离群值", map[int]int{100: 50, 105: 50, 30: 1}, 1, 100, 105},
+		{"离群值过多", map[int]int{100: 50, 105: 50, 30: 2}, 1, 30, 105},
+	}
+	for _, c := range cases {
+		var hist [MaxHue + 1]int
+		for h, n := range c.hues {
+			hist[h] = n
+		}
+		if lo, hi := hueRange(hist, c.skip); lo != c.min || hi != c.max {
+			t.Errorf("%s: 色相范围 = %d~%d, 期望 %d~%d", c.name, lo, hi, c.min, c.max)
+		}
+	}
+}
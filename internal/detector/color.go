@@ -1,21 +1,19 @@
 package detector
 
 import (
-	"image"
-	"image/color"
+	"log"
 
 	"gocv.io/x/gocv"
 )
 
 type ColorDetectParam struct {
 	Img            gocv.Mat
-	MinColor       color.RGBA
-	MaxColor       color.RGBA
+	Range          HSVRange
 	ScoreThreshold float64
 }
 
 type ColorDetector interface {
-	Detect(param ColorDetectParam) ([]image.Rectangle, []float64, bool)
+	Detect(param ColorDetectParam) ([]Detection, bool)
 }
 
 type ColorDetectorImpl struct {
@@ -25,52 +23,49 @@ func NewColorDetector() ColorDetector {
 	return &ColorDetectorImpl{}
 }
 
-func NewColorDetectParam(img gocv.Mat, minColor color.RGBA, maxColor color.RGBA, scoreThreshold float32) ColorDetectParam {
+func NewColorDetectParam(img gocv.Mat, hsvRange HSVRange, scoreThreshold float32) ColorDetectParam {
 	return ColorDetectParam{
 		Img:            img,
-		MinColor:       minColor,
-		MaxColor:       maxColor,
+		Range:          hsvRange,
 		ScoreThreshold: float64(scoreThreshold),
 	}
 }
 
-func (d *ColorDetectorImpl) Detect(param ColorDetectParam) ([]image.Rectangle, []float64, bool) {
+func (d *ColorDetectorImpl) Detect(param ColorDetectParam) ([]Detection, bool) {
 	img := param.Img
-	mincolor := param.MinColor
-	maxcolor := param.MaxColor
+	hsvRange := param.Range
 	scoreThreshold := param.ScoreThreshold
+	if err := hsvRange.Validate(); err != nil {
+		log.Printf("[检测器] 颜色范围错误: %v\n", err)
+		return nil, false
+	}
 
 	imgHsv := gocv.NewMat()
 	mask := gocv.NewMat()
 	defer imgHsv.Close()
 	defer mask.Close()
 
-	gocv.CvtColor(img, &imgHsv, gocv.ColorBGRToHSV)
-
-	lower := gocv.NewScalar(float64(mincolor.R), float64(mincolor.G), float64(mincolor.B), 0) // HSV下限
-	upper := gocv.NewScalar(float64(maxcolor.R), float64(maxcolor.G), float64(maxcolor.B), 0) // HSV上限
-	gocv.InRangeWithScalar(imgHsv, lower, upper, &mask)
+	toHSV(img, &imgHsv)
+	hsvRange.mask(imgHsv, &mask)
 
 	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
 	if contours.Size() == 0 {
-		return nil, nil, false
+		return nil, false
 	}
 
-	var rectList []image.Rectangle
-	var sizeList []float64
+	var list []Detection
 	for i := range contours.Size() {
 		contour := contours.At(i)
 		area := gocv.ContourArea(contour)
 		if area <= scoreThreshold {
 			continue
 		}
-		rect := gocv.BoundingRect(contour)
-		rectList = append(rectList, rect)
-		sizeList = append(sizeList, area)
+		list = append(list, Detection{Rect: gocv.BoundingRect(contour), Score: area, ClassID: -1, Source: SourceColor})
 	}
+	return list, len(list) > 0
+}
 
-	if len(rectList) == 0 {
-		return nil, nil, false
-	}
-	return rectList, sizeList, true
+// 转换为HSV图像，预设的颜色范围都是按此转换测量的，校准颜色时也必须使用此方法
+func toHSV(img gocv.Mat, dst *gocv.Mat) {
+	gocv.CvtColor(img, dst, gocv.ColorBGRToHSV)
 }
@@ -0,0 +1,96 @@
+package detector
+
+import (
+	"image"
+	"star-map-tool/internal/game"
+
+	"gocv.io/x/gocv"
+)
+
+// 识别结果的来源
+const (
+	SourceColor    = "color"
+	SourceTemplate = "template"
+	SourceDNN      = "dnn"
+)
+
+// 各类识别统一的结果
+type Detection struct {
+	Rect    image.Rectangle // 通过 Locate 识别时为窗口坐标(参考分辨率)，否则相对于被识别的图像
+	Score   float64         // 颜色: 轮廓面积 模板: 匹配度 DNN: 置信度
+	ClassID int             // DNN的类别ID，其他识别为-1
+	Label   string          // DNN的类别名称 或 模板名称
+	Source  string
+}
+
+// 通用的识别接口，识别参数在创建时绑定
+// 策略只依赖此接口时，可以在不修改调用处的情况下更换识别方式
+type Detector interface {
+	Find(img gocv.Mat) ([]Detection, bool)
+}
+
+type DetectorFunc func(img gocv.Mat) ([]Detection, bool)
+
+func (f DetectorFunc) Find(img gocv.Mat) ([]Detection, bool) {
+	return f(img)
+}
+
+// 按颜色识别，面积不大于 scoreThreshold 的区域会被忽略
+func Color(d ColorDetector, hsvRange HSVRange, scoreThreshold float32) Detector {
+	return DetectorFunc(func(img gocv.Mat) ([]Detection, bool) {
+		return d.Detect(NewColorDetectParam(img, hsvRange, scoreThreshold))
+	})
+}
+
+// 按图片模板识别，scales 为模板的缩放比例，为空时只按原大小匹配
+func Template(d TemplateDetector, templateName string, scoreThreshold float32, scales ...float64) Detector {
+	return DetectorFunc(func(img gocv.Mat) ([]Detection, bool) {
+		param := NewTemplateDetectParam(img, templateName, scoreThreshold)
+		param.Scales = scales
+		return d.Detect(param)
+	})
+}
+
+// 按模型识别，阈值为0时使用模型推荐值，filter 为空时返回所有类别
+func DNN(d DNNDetector, scoreThreshold float32, nmsThreshold float32, filter ...int) Detector {
+	return DetectorFunc(func(img gocv.Mat) ([]Detection, bool) {
+		return d.Detect(NewDNNDetectParam(img, scoreThreshold, nmsThreshold), filter...)
+	})
+}
+
+// 截取游戏窗口中的 region 区域(参考坐标，为空时截取整个窗口)进行识别，结果转换为窗口坐标
+func Locate(g *game.Game, region image.Rectangle, d Detector) ([]Detection, bool) {
+	var img gocv.Mat
+	var err error
+	if region.Empty() {
+		img, err = g.GetScreenshotMatRGB()
+	} else {
+		img, err = g.GetScreenshotMatRGB(region.Min.X, region.Min.Y, region.Dx(), region.Dy())
+	}
+	if err != nil {
+		return nil, false
+	}
+	defer img.Close()
+
+	list, ok := d.Find(img)
+	for i := range list {
+		list[i].Rect = list[i].Rect.Add(region.Min)
+	}
+	return list, ok
+}
+
+func Rects(list []Detection) []image.Rectangle {
+	rects := make([]image.Rectangle, 0, len(list))
+	for _, d := range list {
+		rects = append(rects, d.Rect)
+	}
+	return rects
+}
+
+func ClassIDs(list []Detection) []int {
+	ids := make([]int, 0, len(list))
+	for _, d := range list {
+		ids = append(ids, d.ClassID)
+	}
+	return ids
+}
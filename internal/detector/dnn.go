@@ -1,31 +1,77 @@
 package detector
 
 import (
+	"errors"
+	"fmt"
 	"image"
+	"log"
+	"sync"
 
 	"gocv.io/x/gocv"
 )
 
 type DNNDetectParam struct {
 	Img            gocv.Mat
-	ScoreThreshold float32
-	NMSThreshold   float32
+	ScoreThreshold float32 // 0代表使用模型推荐的置信度
+	NMSThreshold   float32 // 0代表使用模型推荐的NMS阈值
 }
 
 type DNNDetector interface {
-	Detect(param DNNDetectParam, filter ...int) ([]image.Rectangle, []int, bool)
+	Detect(param DNNDetectParam, filter ...int) ([]Detection, bool)
+	ClassName(classId int) string
+	Bundle() *ModelBundle
+	Close() error
 }
 
+// 模型的推理设置
+type DNNConfig struct {
+	Backend  gocv.NetBackendType
+	Target   gocv.NetTargetType
+	PoolSize int // 最多同时进行推理的数量，每个推理都持有一份模型
+}
+
+// 新建检测器时使用的默认设置，可通过命令行参数修改
+var DefaultDNNConfig = DNNConfig{
+	Backend:  gocv.NetBackendDefault,
+	Target:   gocv.NetTargetCPU,
+	PoolSize: 2,
+}
+
+// 模型只在创建时加载一次，推理时从池中取出，用完放回，关闭检测器时释放
 type DNNDetectorImpl struct {
-	modePath  string
-	modeBytes []byte
+	bundle *ModelBundle
+	config DNNConfig
+	decode yoloDecoder // 按模型的输出格式解码
+
+	idle    chan *gocv.Net // 空闲的模型
+	lock    sync.Mutex
+	created int // 已加载的模型数量
+	closed  bool
 }
 
-func NewDNNDetector(modePath string, modeBytes []byte) DNNDetector {
-	return &DNNDetectorImpl{
-		modePath:  modePath,
-		modeBytes: modeBytes,
+func NewDNNDetector(bundle *ModelBundle, config DNNConfig) (DNNDetector, error) {
+	if config.PoolSize <= 0 {
+		config.PoolSize = 1
 	}
+	decode, err := getYOLODecoder(bundle.Format)
+	if err != nil {
+		return nil, err
+	}
+	d := &DNNDetectorImpl{
+		bundle: bundle,
+		config: config,
+		decode: decode,
+		idle:   make(chan *gocv.Net, config.PoolSize),
+	}
+
+	// 预先加载一份模型，确认模型可用
+	net, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	d.created = 1
+	d.idle <- net
+	return d, nil
 }
 
 func NewDNNDetectParam(img gocv.Mat, scoreThreshold float32, nmsThreshold float32) DNNDetectParam {
@@ -36,41 +82,139 @@ func NewDNNDetectParam(img gocv.Mat, scoreThreshold float32, nmsThreshold float3
 	}
 }
 
-func (d *DNNDetectorImpl) Detect(param DNNDetectParam, filter ...int) ([]image.Rectangle, []int, bool) {
-	modePath := d.modePath
-	modeFile := d.modeBytes
-	img := param.Img
-	scoreThreshold := param.ScoreThreshold // 当前测试用的0.4
-	nmsThreshold := param.NMSThreshold     // 当前测试用的0.45
+// 使用模型推荐的置信度与NMS阈值
+func NewDNNDefaultParam(img gocv.Mat) DNNDetectParam {
+	return DNNDetectParam{Img: img}
+}
+
+func (d *DNNDetectorImpl) load() (*gocv.Net, error) {
+	net, err := gocv.ReadNetFromONNXBytes(d.bundle.Model)
+	if err != nil {
+		return nil, fmt.Errorf("加载模型失败: %w", err)
+	}
+	if net.Empty() {
+		net.Close()
+		return nil, errors.New("加载模型失败: 模型为空")
+	}
+	if err := net.SetPreferableBackend(d.config.Backend); err != nil {
+		net.Close()
+		return nil, fmt.Errorf("设置推理后端失败: %w", err)
+	}
+	if err := net.SetPreferableTarget(d.config.Target); err != nil {
+		net.Close()
+		return nil, fmt.Errorf("设置推理设备失败: %w", err)
+	}
+	return &net, nil
+}
 
-	// 加载模型
-	var net gocv.Net
-	if len(modeFile) > 0 {
-		n, err := gocv.ReadNetFromONNXBytes(modeFile)
+// 取出一份空闲的模型，没有空闲且未达到上限时加载新的模型，否则等待其他推理结束
+func (d *DNNDetectorImpl) acquire() (*gocv.Net, error) {
+	select {
+	case net, ok := <-d.idle:
+		if ok {
+			return net, nil
+		}
+		return nil, errDNNClosed
+	default:
+	}
+
+	d.lock.Lock()
+	if d.closed {
+		d.lock.Unlock()
+		return nil, errDNNClosed
+	}
+	if d.created < d.config.PoolSize {
+		d.created++
+		d.lock.Unlock()
+
+		net, err := d.load()
 		if err != nil {
-			panic(err)
-		} else {
-			net = n
+			d.lock.Lock()
+			d.created--
+			d.lock.Unlock()
 		}
-	} else {
-		net = gocv.ReadNetFromONNX(modePath)
+		return net, err
 	}
-	if net.Empty() {
-		panic("Error loading ONNX model")
+	d.lock.Unlock()
+
+	if net, ok := <-d.idle; ok {
+		return net, nil
+	}
+	return nil, errDNNClosed
+}
+
+func (d *DNNDetectorImpl) release(net *gocv.Net) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		net.Close() // 检测器已关闭，推理结束后再释放
+		return
+	}
+	d.idle <- net
+}
+
+var errDNNClosed = errors.New("检测器已关闭")
+
+// 释放所有空闲的模型，正在推理的模型在推理结束后释放
+func (d *DNNDetectorImpl) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	close(d.idle)
+	for net := range d.idle {
+		net.Close()
+	}
+	return nil
+}
+
+func (d *DNNDetectorImpl) ClassName(classId int) string {
+	return d.bundle.ClassName(classId)
+}
+
+func (d *DNNDetectorImpl) Bundle() *ModelBundle {
+	return d.bundle
+}
+
+func (d *DNNDetectorImpl) Detect(param DNNDetectParam, filter ...int) ([]Detection, bool) {
+	img := param.Img
+	scoreThreshold := param.ScoreThreshold
+	if scoreThreshold <= 0 {
+		scoreThreshold = d.bundle.ScoreThreshold
+	}
+	nmsThreshold := param.NMSThreshold
+	if nmsThreshold <= 0 {
+		nmsThreshold = d.bundle.NMSThreshold
+	}
+	inputSize := d.bundle.InputSize
+
+	net, err := d.acquire()
+	if err != nil {
+		log.Printf("[检测器] %v\n", err)
+		return nil, false
+	}
+	defer d.release(net)
+
+	// 等比缩放后填充为模型需要的正方形，避免图像变形
+	box := newLetterbox(img.Cols(), img.Rows(), inputSize)
+	padded, err := box.apply(img, inputSize)
+	if err != nil {
+		log.Printf("[检测器] 图像预处理失败: %v\n", err)
+		return nil, false
 	}
-	net.SetPreferableBackend(gocv.NetBackendDefault)
-	net.SetPreferableTarget(gocv.NetTargetCPU)
-	defer net.Close()
+	defer padded.Close()
 
 	// 图像转为模型需要的形式
-	blob := gocv.BlobFromImage(img, 1.0/255.0, image.Pt(1024, 1024), gocv.NewScalar(0, 0, 0, 0), true, false)
+	blob := gocv.BlobFromImage(padded, 1.0/255.0, image.Pt(inputSize, inputSize), gocv.NewScalar(0, 0, 0, 0), true, false)
 	defer blob.Close()
 	net.SetInput(blob, "")
 
 	// 推理
-	outputNames := getOutputNames(net)
+	outputNames := getOutputNames(*net)
 	if len(outputNames) == 0 {
-		return nil, nil, false
+		return nil, false
 	}
 	outs := net.ForwardLayers(outputNames) // 张量集合
 	defer func() {
@@ -79,72 +223,62 @@ func (d *DNNDetectorImpl) Detect(param DNNDetectParam, filter ...int) ([]image.R
 		}
 	}()
 
-	boxes, confidences, classIds := performDetection(&outs, img.Cols(), img.Rows(), scoreThreshold)
-	if len(boxes) == 0 {
-		return nil, nil, false
+	var candidates []yoloBox
+	for _, out := range outs {
+		data, err := out.DataPtrFloat32()
+		if err != nil {
+			log.Printf("[检测器] 读取模型输出失败: %v\n", err)
+			return nil, false
+		}
+		list, err := d.decode(data, out.Size(), scoreThreshold)
+		if err != nil {
+			log.Printf("[检测器] %v\n", err)
+			return nil, false
+		}
+		candidates = append(candidates, list...)
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	boxes := make([]image.Rectangle, len(candidates))
+	confidences := make([]float32, len(candidates))
+	for i, c := range candidates {
+		boxes[i] = box.unmap(c)
+		confidences[i] = c.score
+	}
+
+	// NMS，端到端模型已在模型内完成
+	var indices []int
+	if d.bundle.Format == FormatE2E {
+		for i := range candidates {
+			indices = append(indices, i)
+		}
+	} else {
+		indices = gocv.NMSBoxes(boxes, confidences, scoreThreshold, nmsThreshold)
 	}
-	// NMS
-	indices := gocv.NMSBoxes(boxes, confidences, scoreThreshold, nmsThreshold)
 
 	m := make(map[int]bool)
 	for _, v := range filter {
 		m[v] = true
 	}
 
-	var targetBoxList []image.Rectangle
-	var classIdList []int
+	var list []Detection
 	filterLength := len(filter)
 	for _, idx := range indices {
-		if idx == 0 {
-			continue
-		}
-
-		classId := classIds[idx]
+		classId := candidates[idx].classId
 		if filterLength > 0 && !m[classId] { // 用户要求获取指定classId的数据
 			continue
 		}
-		targetBoxList = append(targetBoxList, boxes[idx])
-		classIdList = append(classIdList, classIds[idx])
+		list = append(list, Detection{
+			Rect:    boxes[idx],
+			Score:   float64(confidences[idx]),
+			ClassID: classId,
+			Label:   d.bundle.ClassName(classId),
+			Source:  SourceDNN,
+		})
 	}
-	return targetBoxList, classIdList, len(classIdList) > 0
-}
-
-func performDetection(outs *[]gocv.Mat, imgW, imgH int, scoreThreshold float32) ([]image.Rectangle, []float32, []int) {
-	var boxes []image.Rectangle
-	var confidences []float32
-	var classIds []int
-
-	const inputSize float32 = 1024 // 当前模型训练尺寸是1024
-	for _, out := range *outs {
-		gocv.TransposeND(out, []int{0, 2, 1}, &out)
-		out = out.Reshape(1, out.Size()[1])
-		defer out.Close()
-
-		scaleX := float32(imgW) / inputSize
-		scaleY := float32(imgH) / inputSize
-		for i := 0; i < out.Rows(); i++ {
-			confidence, classId := getScoreAndClassId(out, i)
-			if confidence < scoreThreshold {
-				continue
-			}
-
-			centerX := out.GetFloatAt(i, 0)
-			centerY := out.GetFloatAt(i, 1)
-			w := out.GetFloatAt(i, 2)
-			h := out.GetFloatAt(i, 3)
-
-			left := (centerX - w/2) * scaleX
-			top := (centerY - h/2) * scaleY
-			right := (centerX + w/2) * scaleX
-			bottom := (centerY + h/2) * scaleY
-
-			boxes = append(boxes, image.Rect(int(left), int(top), int(right), int(bottom)))
-
-			confidences = append(confidences, confidence)
-			classIds = append(classIds, classId)
-		}
-	}
-	return boxes, confidences, classIds
+	return list, len(list) > 0
 }
 
 func getOutputNames(net gocv.Net) []string {
@@ -159,26 +293,3 @@ func getOutputNames(net gocv.Net) []string {
 
 	return outputLayers
 }
-
-func getScoreAndClassId(out gocv.Mat, row int) (float32, int) {
-	// x, y, w, h, class1_score, class2_score, class3_score...
-
-	cols := out.Cols()
-	if cols < 5 {
-		// Yolo 不可能返回小于5的列数
-		return 0, -1
-	} else if cols == 5 {
-		return out.GetFloatAt(row, 4), 0
-	}
-
-	classId := 0
-	maxScore := out.GetFloatAt(row, 4)
-	for i := 5; i < cols; i++ {
-		score := out.GetFloatAt(row, i)
-		if score > maxScore {
-			maxScore = score
-			classId = i - 4
-		}
-	}
-	return maxScore, classId
-}
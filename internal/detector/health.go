@@ -0,0 +1,134 @@
+package detector
+
+import (
+	"fmt"
+	"log"
+
+	"gocv.io/x/gocv"
+)
+
+// 血条的颜色状态
+type HealthState string
+
+const (
+	HealthNone HealthState = "none" // 未识别到血条
+	HealthRed  HealthState = "red"  // 正常
+	HealthGray HealthState = "gray" // 灰色，Boss在无敌、超度等阶段的血条，游戏中没有单独的无敌颜色
+)
+
+// 血条的一种颜色，与状态对应
+type HealthColor struct {
+	State HealthState
+	Range HSVRange
+}
+
+// 血条的识别结果
+type HealthReading struct {
+	Percent    float64     // 剩余血量 0~100
+	State      HealthState // 占比最多的颜色对应的状态
+	Confidence float64     // 0~1，识别结果符合从左向右填充的血条的程度
+}
+
+// 是否处于无法造成伤害的状态，灰色血条即代表无敌
+func (r HealthReading) Invulnerable() bool {
+	return r.State == HealthGray
+}
+
+func (r HealthReading) String() string {
+	return fmt.Sprintf("%.1f%% (%s 可信度%.2f)", r.Percent, r.State, r.Confidence)
+}
+
+// 血条分析器，img 需是只包含血条的区域
+type HealthAnalyzer interface {
+	Analyze(img gocv.Mat) HealthReading
+}
+
+type HealthAnalyzerImpl struct {
+	colors []HealthColor
+}
+
+func NewHealthAnalyzer(colors ...HealthColor) HealthAnalyzer {
+	return &HealthAnalyzerImpl{colors: colors}
+}
+
+func (a *HealthAnalyzerImpl) Analyze(img gocv.Mat) HealthReading {
+	if img.Empty() {
+		return HealthReading{State: HealthNone}
+	}
+
+	hsv := gocv.NewMat()
+	defer hsv.Close()
+	toHSV(img, &hsv)
+
+	best := HealthReading{State: HealthNone}
+	bestFilled := 0
+	for _, c := range a.colors {
+		if err := c.Range.Validate(); err != nil {
+			log.Printf("[检测器] 血条颜色 %s 的范围错误: %v\n", c.State, err)
+			continue
+		}
+		columns, err := columnCounts(hsv, c.Range)
+		if err != nil {
+			log.Printf("[检测器] 血条分析失败: %v\n", err)
+			return HealthReading{State: HealthNone}
+		}
+		reading, filled := readHealthBar(columns)
+		if filled > bestFilled {
+			reading.State = c.State
+			best, bestFilled = reading, filled
+		}
+	}
+	return best
+}
+
+// 每一列中颜色范围内的像素数
+func columnCounts(hsv gocv.Mat, r HSVRange) ([]int, error) {
+	mask := gocv.NewMat()
+	sum := gocv.NewMat()
+	defer mask.Close()
+	defer sum.Close()
+
+	r.mask(hsv, &mask)
+	if err := gocv.Reduce(mask, &sum, 0, gocv.ReduceSum, gocv.MatTypeCV32S); err != nil {
+		return nil, err
+	}
+	columns := make([]int, sum.Cols())
+	for x := range columns {
+		columns[x] = int(sum.GetIntAt(0, x)) / 255
+	}
+	return columns, nil
+}
+
+// 按列统计填充程度，血条从左向右填充，中间的数字等遮挡只影响少数几列
+// 返回识别结果与被填充的列数
+func readHealthBar(columns []int) (HealthReading, int) {
+	width := len(columns)
+	thickness := 0 // 血条的高度，取填充最多的一列
+	for _, n := range columns {
+		thickness = max(thickness, n)
+	}
+	if width == 0 || thickness < 2 {
+		return HealthReading{State: HealthNone}, 0
+	}
+
+	filled, solid := 0, 0
+	for _, n := range columns {
+		if n*2 >= thickness {
+			filled++
+			solid += n
+		}
+	}
+
+	// 剩余血量为 filled 时，左侧的 filled 列应全部被填充，右侧全部为空
+	consistent := 0
+	for x, n := range columns {
+		if (n*2 >= thickness) == (x < filled) {
+			consistent++
+		}
+	}
+
+	return HealthReading{
+		Percent:    float64(filled) / float64(width) * 100,
+		Confidence: float64(consistent) / float64(width) * float64(solid) / float64(filled*thickness),
+	}, filled
+}
@@ -0,0 +1,76 @@
+package detector
+
+import (
+	"math"
+	"testing"
+)
+
+// 宽度为 width 的血条，左侧 filled 列的填充高度为 thickness
+func healthColumns(width, filled, thickness int) []int {
+	columns := make([]int, width)
+	for x := range filled {
+		columns[x] = thickness
+	}
+	return columns
+}
+
+func TestReadHealthBar(t *testing.T) {
+	occluded := healthColumns(100, 40, 10)
+	occluded[20] = 2 // 数字遮挡了大部分
+
+	partial := healthColumns(100, 40, 10)
+	partial[20] = 6 // 遮挡不到一半，仍算作填充
+
+	alternating := make([]int, 100)
+	for x := 0; x < len(alternating); x += 2 {
+		alternating[x] = 10
+	}
+
+	cases := []struct {
+		name       string
+		columns    []int
+		percent    float64
+		confidence float64
+		filled     int
+	}{
+		{"满血", healthColumns(100, 100, 10), 100, 1, 100},
+		{"部分血量", healthColumns(200, 50, 8), 25, 1, 50},
+		{"遮挡的列", occluded, 39, 0.98, 39},
+		{"部分遮挡的列", partial, 40, 0.99, 40},
+		{"不连续的填充", alternating, 50, 0.5, 50},
+	}
+	for _, c := range cases {
+		reading, filled := readHealthBar(c.columns)
+		if filled != c.filled {
+			t.Errorf("%s: 填充列数 = %d, 期望 %d", c.name, filled, c.filled)
+		}
+		if math.Abs(reading.Percent-c.percent) > 1e-9 {
+			t.Errorf("%s: 血量 = %v, 期望 %v", c.name, reading.Percent, c.percent)
+		}
+		if math.Abs(reading.Confidence-c.confidence) > 1e-9 {
+			t.Errorf("%s: 可信度 = %v, 期望 %v", c.name, reading.Confidence, c.confidence)
+		}
+	}
+}
+
+func TestReadHealthBarNone(t *testing.T) {
+	cases := map[string][]int{
+		"空":   nil,
+		"无填充": make([]int, 100),
+		"噪点":  healthColumns(100, 30, 1), // 高度不足2像素
+	}
+	for name, columns := range cases {
+		if reading, filled := readHealthBar(columns); reading.State != HealthNone || filled != 0 {
+			t.Errorf("%s: 结果 = %v 填充列数 %d, 期望未识别到血条", name, reading, filled)
+		}
+	}
+}
+
+func TestHealthReadingInvulnerable(t *testing.T) {
+	if !(HealthReading{State: HealthGray}).Invulnerable() {
+		t.Error("灰色血条应视为无敌")
+	}
+	if (HealthReading{State: HealthRed}).Invulnerable() {
+		t.Error("红色血条不应视为无敌")
+	}
+}
@@ -0,0 +1,127 @@
+package detector
+
+import (
+	"fmt"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// OpenCV 中8位图像的HSV取值上限
+const (
+	MaxHue        = 179 // 色相为角度的一半: 0~179
+	MaxSaturation = 255
+	MaxValue      = 255
+)
+
+// HSV颜色，使用OpenCV的单位: H 0~179, S 0~255, V 0~255
+type HSV struct {
+	H, S, V int
+}
+
+// 由常用的单位创建: 色相为角度 0~360，饱和度与亮度为百分比 0~100
+func HSVDegrees(h, s, v float64) HSV {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return HSV{
+		H: int(math.Round(h/2)) % (MaxHue + 1),
+		S: int(math.Round(s / 100 * MaxSaturation)),
+		V: int(math.Round(v / 100 * MaxValue)),
+	}
+}
+
+// 转换为常用的单位: 角度 与 百分比
+func (c HSV) Degrees() (float64, float64, float64) {
+	return float64(c.H) * 2, float64(c.S) / MaxSaturation * 100, float64(c.V) / MaxValue * 100
+}
+
+func (c HSV) String() string {
+	return fmt.Sprintf("{%d, %d, %d}", c.H, c.S, c.V)
+}
+
+// HSV颜色范围，各分量都包含边界值
+// Min.H 大于 Max.H 时代表跨越红色的色相范围，即 [Min.H, 179] 与 [0, Max.H] 两段
+type HSVRange struct {
+	Min HSV
+	Max HSV
+}
+
+// 创建颜色范围，参数使用OpenCV的单位
+func NewHSVRange(min, max HSV) (HSVRange, error) {
+	r := HSVRange{Min: min, Max: max}
+	return r, r.Validate()
+}
+
+// 用于预设的颜色范围，范围错误时直接panic
+func MustHSVRange(min, max HSV) HSVRange {
+	r, err := NewHSVRange(min, max)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r HSVRange) Validate() error {
+	for _, c := range []HSV{r.Min, r.Max} {
+		if c.H < 0 || c.H > MaxHue {
+			return fmt.Errorf("色相 %d 超出范围 0~%d", c.H, MaxHue)
+		}
+		if c.S < 0 || c.S > MaxSaturation {
+			return fmt.Errorf("饱和度 %d 超出范围 0~%d", c.S, MaxSaturation)
+		}
+		if c.V < 0 || c.V > MaxValue {
+			return fmt.Errorf("亮度 %d 超出范围 0~%d", c.V, MaxValue)
+		}
+	}
+	if r.Min.S > r.Max.S || r.Min.V > r.Max.V {
+		return fmt.Errorf("颜色范围 %v 的下限大于上限", r)
+	}
+	return nil
+}
+
+// 是否跨越红色(色相首尾相接处)
+func (r HSVRange) Wraps() bool {
+	return r.Min.H > r.Max.H
+}
+
+func (r HSVRange) Contains(c HSV) bool {
+	if c.S < r.Min.S || c.S > r.Max.S || c.V < r.Min.V || c.V > r.Max.V {
+		return false
+	}
+	if r.Wraps() {
+		return c.H >= r.Min.H || c.H <= r.Max.H
+	}
+	return c.H >= r.Min.H && c.H <= r.Max.H
+}
+
+func (r HSVRange) String() string {
+	return fmt.Sprintf("%v ~ %v", r.Min, r.Max)
+}
+
+// 生成 InRange 使用的上下限，跨越红色时拆分为两段
+func (r HSVRange) bounds() [][2]gocv.Scalar {
+	scalar := func(h, s, v int) gocv.Scalar {
+		return gocv.NewScalar(float64(h), float64(s), float64(v), 0)
+	}
+	if !r.Wraps() {
+		return [][2]gocv.Scalar{{scalar(r.Min.H, r.Min.S, r.Min.V), scalar(r.Max.H, r.Max.S, r.Max.V)}}
+	}
+	return [][2]gocv.Scalar{
+		{scalar(r.Min.H, r.Min.S, r.Min.V), scalar(MaxHue, r.Max.S, r.Max.V)},
+		{scalar(0, r.Min.S, r.Min.V), scalar(r.Max.H, r.Max.S, r.Max.V)},
+	}
+}
+
+// 生成颜色范围内的掩码，mask 由调用层创建与关闭
+func (r HSVRange) mask(hsv gocv.Mat, mask *gocv.Mat) {
+	bounds := r.bounds()
+	gocv.InRangeWithScalar(hsv, bounds[0][0], bounds[0][1], mask)
+	for _, b := range bounds[1:] {
+		band := gocv.NewMat()
+		gocv.InRangeWithScalar(hsv, b[0], b[1], &band)
+		gocv.BitwiseOr(*mask, band, mask)
+		band.Close()
+	}
+}
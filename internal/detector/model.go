@@ -0,0 +1,124 @@
+package detector
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 模型目录中的文件
+const (
+	ModelFileName    = "best.onnx"
+	ClassesFileName  = "classes.txt"
+	MetadataFileName = "model.yaml"
+)
+
+// 模型的元数据，对应模型目录中的 model.yaml
+type ModelMetadata struct {
+	Name           string  `yaml:"name"`
+	Version        string  `yaml:"version"`
+	InputSize      int     `yaml:"input_size"`      // 训练时的输入尺寸
+	Format         string  `yaml:"format"`          // 输出格式: yolov5、yolov8(默认，YOLOv11相同)、e2e
+	ScoreThreshold float32 `yaml:"score_threshold"` // 推荐的置信度
+	NMSThreshold   float32 `yaml:"nms_threshold"`   // 推荐的NMS阈值
+}
+
+// 元数据中未填写阈值时使用的默认值
+const (
+	DefaultScoreThreshold float32 = 0.5
+	DefaultNMSThreshold   float32 = 0.45
+)
+
+// 模型包：模型文件、类别名称与元数据
+type ModelBundle struct {
+	ModelMetadata
+	Classes []string // 下标即类别ID
+	Model   []byte
+}
+
+/**
+ * 读取模型目录，目录中需要包含 best.onnx、classes.txt 与 model.yaml
+ * @param fsys 模型所在的文件系统，例如: assets.Models、os.DirFS(".")
+ * @param dir 模型目录，例如: models/sbsc
+ */
+func LoadModelBundle(fsys fs.FS, dir string) (*ModelBundle, error) {
+	bundle := &ModelBundle{}
+
+	data, err := fs.ReadFile(fsys, path.Join(dir, MetadataFileName))
+	if err != nil {
+		return nil, fmt.Errorf("读取模型元数据失败: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &bundle.ModelMetadata); err != nil {
+		return nil, fmt.Errorf("解析模型元数据 %s 失败: %w", path.Join(dir, MetadataFileName), err)
+	}
+	if bundle.InputSize <= 0 {
+		return nil, fmt.Errorf("模型元数据 %s 中缺少 input_size", path.Join(dir, MetadataFileName))
+	}
+	if _, err := getYOLODecoder(bundle.Format); err != nil {
+		return nil, fmt.Errorf("模型元数据 %s 错误: %w", path.Join(dir, MetadataFileName), err)
+	}
+	if bundle.ScoreThreshold <= 0 {
+		bundle.ScoreThreshold = DefaultScoreThreshold
+	}
+	if bundle.NMSThreshold <= 0 {
+		bundle.NMSThreshold = DefaultNMSThreshold
+	}
+
+	data, err = fs.ReadFile(fsys, path.Join(dir, ClassesFileName))
+	if err != nil {
+		return nil, fmt.Errorf("读取模型类别失败: %w", err)
+	}
+	if bundle.Classes, err = parseClasses(string(data)); err != nil {
+		return nil, fmt.Errorf("解析模型类别 %s 失败: %w", path.Join(dir, ClassesFileName), err)
+	}
+
+	if bundle.Model, err = fs.ReadFile(fsys, path.Join(dir, ModelFileName)); err != nil {
+		return nil, fmt.Errorf("读取模型文件失败: %w", err)
+	}
+	return bundle, nil
+}
+
+// 每行一个类别，格式为 "ID: 名称" 或 "名称"（按行号作为ID）
+func parseClasses(text string) ([]string, error) {
+	var classes []string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		id, name := len(classes), line
+		if before, after, ok := strings.Cut(line, ":"); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(before))
+			if err != nil {
+				return nil, fmt.Errorf("第%d行的类别ID错误: %s", i+1, before)
+			}
+			id, name = n, strings.TrimSpace(after)
+		}
+		if id != len(classes) {
+			return nil, fmt.Errorf("第%d行的类别ID应为%d", i+1, len(classes))
+		}
+		classes = append(classes, name)
+	}
+	if len(classes) == 0 {
+		return nil, errors.New("没有任何类别")
+	}
+	return classes, nil
+}
+
+// 类别ID对应的名称，未知的ID返回ID本身
+func (b *ModelBundle) ClassName(classId int) string {
+	if classId < 0 || classId >= len(b.Classes) {
+		return strconv.Itoa(classId)
+	}
+	return b.Classes[classId]
+}
+
+func (b *ModelBundle) String() string {
+	return fmt.Sprintf("%s(%s) 输入尺寸:%d 类别:%d个", b.Name, b.Version, b.InputSize, len(b.Classes))
+}
@@ -1,34 +1,71 @@
 package detector
 
 import (
+	"errors"
+	"fmt"
 	"image"
+	"io/fs"
 	"log"
-	"path/filepath"
+	"path"
+	"slices"
+	"strings"
 
 	"gocv.io/x/gocv"
 )
 
+// 多个匹配结果的重叠比例超过此值时只保留匹配度最高的
+const DefaultTemplateNMSThreshold = 0.3
+
+// 每个缩放比例最多取出的匹配结果，避免阈值过低时耗时过长
+const maxTemplateMatches = 100
+
 type TemplateDetectParam struct {
 	Img            gocv.Mat
 	TemplateName   string
 	ScoreThreshold float32
+	Scales         []float64 // 模板的缩放比例，为空时只按原大小匹配
+	NMSThreshold   float32   // 为0时使用 DefaultTemplateNMSThreshold
+	MaxResults     int       // 最多返回的结果数，0代表不限制
 }
 
 type TemplateDetector interface {
-	Detect(param *TemplateDetectParam) (*image.Rectangle, bool)
+	Detect(param *TemplateDetectParam) ([]Detection, bool)
+	Match(param *TemplateDetectParam) ([]Detection, error) // 与 Detect 相同，但返回失败的原因
+	Names() []string
+	Close()
+}
+
+// 图片模板，PNG带透明通道时透明的部分不参与匹配
+type imageTemplate struct {
+	img  gocv.Mat
+	mask gocv.Mat // 无透明通道时为空
 }
 
 type TemplateDetectorImpl struct {
-	templates map[string]gocv.Mat
+	templates map[string]imageTemplate
 }
 
-// 不推荐使用模板匹配，非常容易收到各类因素影响而无法识别（比较标准颜色、尺寸的图标可以使用局部截取后识别）
-func NewTemplateDetector(templateDir string) TemplateDetector {
-	d := &TemplateDetectorImpl{
-		templates: make(map[string]gocv.Mat),
+/**
+ * 加载 dir 目录下的全部PNG模板，模板名称为不含扩展名的文件名
+ * 模板需从参考分辨率(1280x800)的截图中截取，适用于位置与外观固定的界面图标
+ * @param fsys 模板所在的文件系统，例如: assets.Templates、os.DirFS(".")
+ */
+func NewTemplateDetector(fsys fs.FS, dir string) (TemplateDetector, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.png"))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片模板文件失败: %w", err)
+	}
+
+	d := &TemplateDetectorImpl{templates: make(map[string]imageTemplate)}
+	for _, file := range files {
+		template, err := loadTemplate(fsys, file)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.templates[strings.TrimSuffix(path.Base(file), ".png")] = template
 	}
-	loadTemplates(d, templateDir)
-	return d
+	return d, nil
 }
 
 func NewTemplateDetectParam(img gocv.Mat, templateName string, scoreThreshold float32) *TemplateDetectParam {
@@ -39,53 +76,185 @@ func NewTemplateDetectParam(img gocv.Mat, templateName string, scoreThreshold fl
 	}
 }
 
-func (d *TemplateDetectorImpl) Detect(param *TemplateDetectParam) (*image.Rectangle, bool) {
-	templateName := param.TemplateName
-	img := param.Img
-	scoreThreshold := param.ScoreThreshold
+func loadTemplate(fsys fs.FS, file string) (imageTemplate, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return imageTemplate{}, fmt.Errorf("读取图片模板 %s 失败: %w", file, err)
+	}
+	img, err := gocv.IMDecode(data, gocv.IMReadUnchanged)
+	if err != nil || img.Empty() {
+		img.Close()
+		return imageTemplate{}, fmt.Errorf("无法加载图片模板: %s", file)
+	}
+	defer img.Close()
 
-	result := gocv.NewMat()
-	defer result.Close()
+	// 截图与 IMDecode 解码的图片都是BGR，去掉透明通道即可
+	template := imageTemplate{img: gocv.NewMat(), mask: gocv.NewMat()}
+	switch img.Channels() {
+	case 4:
+		gocv.CvtColor(img, &template.img, gocv.ColorBGRAToBGR)
+		channels := gocv.Split(img)
+		gocv.Threshold(channels[3], &template.mask, 0, 255, gocv.ThresholdBinary)
+		for _, c := range channels {
+			c.Close()
+		}
+	case 3:
+		img.CopyTo(&template.img)
+	case 1:
+		gocv.CvtColor(img, &template.img, gocv.ColorGrayToBGR)
+	default:
+		template.close()
+		return imageTemplate{}, fmt.Errorf("图片模板 %s 的通道数 %d 不支持", file, img.Channels())
+	}
+	return template, nil
+}
 
-	template := getTemplateByName(d, templateName)
-	gocv.MatchTemplate(img, template, &result, gocv.TmCcoeffNormed, gocv.NewMat())
+func (t imageTemplate) close() {
+	t.img.Close()
+	t.mask.Close()
+}
 
-	_, maxVal, _, maxLoc := gocv.MinMaxLoc(result)
-	if maxVal < scoreThreshold {
-		return nil, false
+func (d *TemplateDetectorImpl) Close() {
+	for _, t := range d.templates {
+		t.close()
 	}
+	d.templates = nil
+}
 
-	rect := image.Rect(
-		maxLoc.X,
-		maxLoc.Y,
-		maxLoc.X+template.Cols(),
-		maxLoc.Y+template.Rows(),
-	)
-	return &rect, true
+func (d *TemplateDetectorImpl) Names() []string {
+	var names []string
+	for name := range d.templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
 }
 
-func loadTemplates(d *TemplateDetectorImpl, templateDir string) {
-	files, err := filepath.Glob(filepath.Join(templateDir, "*.png"))
+func (d *TemplateDetectorImpl) Detect(param *TemplateDetectParam) ([]Detection, bool) {
+	list, err := d.Match(param)
 	if err != nil {
-		log.Printf("[匹配器] 读取图片模板文件失败: %v\n", err)
-		panic(err)
+		log.Printf("[匹配器] %v\n", err)
+		return nil, false
 	}
+	return list, len(list) > 0
+}
 
-	for _, file := range files {
-		template := gocv.IMRead(file, gocv.IMReadColor)
-		if template.Empty() {
-			panic("[匹配器] 无法加载图片模板: " + file)
+func (d *TemplateDetectorImpl) Match(param *TemplateDetectParam) ([]Detection, error) {
+	template, ok := d.templates[param.TemplateName]
+	if !ok {
+		return nil, fmt.Errorf("未找到图片模板: %s", param.TemplateName)
+	}
+	if param.Img.Empty() {
+		return nil, errors.New("图片为空")
+	}
+
+	scales := param.Scales
+	if len(scales) == 0 {
+		scales = []float64{1}
+	}
+	nmsThreshold := param.NMSThreshold
+	if nmsThreshold <= 0 {
+		nmsThreshold = DefaultTemplateNMSThreshold
+	}
+
+	var boxes []image.Rectangle
+	var scores []float32
+	for _, scale := range scales {
+		b, s, err := matchScaled(param.Img, template, scale, param.ScoreThreshold)
+		if err != nil {
+			return nil, err
+		}
+		boxes, scores = append(boxes, b...), append(scores, s...)
+	}
+	if len(boxes) == 0 {
+		return nil, nil
+	}
+
+	// 不同缩放比例、相邻位置的结果会重叠，只保留匹配度最高的
+	indices := gocv.NMSBoxes(boxes, scores, param.ScoreThreshold, nmsThreshold)
+	slices.SortFunc(indices, func(a, b int) int {
+		if scores[a] > scores[b] {
+			return -1
+		} else if scores[a] < scores[b] {
+			return 1
 		}
+		return 0
+	})
+	if param.MaxResults > 0 && len(indices) > param.MaxResults {
+		indices = indices[:param.MaxResults]
+	}
 
-		name := filepath.Base(file)
-		d.templates[name] = template
+	list := make([]Detection, 0, len(indices))
+	for _, idx := range indices {
+		list = append(list, Detection{Rect: boxes[idx], Score: float64(scores[idx]), ClassID: -1, Label: param.TemplateName, Source: SourceTemplate})
 	}
+	return list, nil
 }
 
-func getTemplateByName(d *TemplateDetectorImpl, name string) gocv.Mat {
-	template, ok := d.templates[name]
-	if !ok {
-		panic("[匹配器] 未找到对应图片模板!")
+// 按 scale 缩放模板后匹配，返回匹配度不低于 scoreThreshold 的各个位置
+func matchScaled(img gocv.Mat, template imageTemplate, scale float64, scoreThreshold float32) ([]image.Rectangle, []float32, error) {
+	w := int(float64(template.img.Cols())*scale + 0.5)
+	h := int(float64(template.img.Rows())*scale + 0.5)
+	if w < 4 || h < 4 || w > img.Cols() || h > img.Rows() {
+		return nil, nil, nil // 模板过小无法区分，过大无法匹配
 	}
-	return template
+
+	templ, mask := template.img, template.mask
+	if w != templ.Cols() || h != templ.Rows() {
+		scaled := gocv.NewMat()
+		defer scaled.Close()
+		if err := gocv.Resize(templ, &scaled, image.Pt(w, h), 0, 0, gocv.InterpolationArea); err != nil {
+			return nil, nil, err
+		}
+		templ = scaled
+
+		if !mask.Empty() {
+			scaledMask := gocv.NewMat()
+			defer scaledMask.Close()
+			if err := gocv.Resize(mask, &scaledMask, image.Pt(w, h), 0, 0, gocv.InterpolationNearestNeighbor); err != nil {
+				return nil, nil, err
+			}
+			mask = scaledMask
+		}
+	}
+
+	result := gocv.NewMat()
+	defer result.Close()
+	if err := gocv.MatchTemplate(img, templ, &result, gocv.TmCcoeffNormed, mask); err != nil {
+		return nil, nil, fmt.Errorf("模板匹配失败: %w", err)
+	}
+	if !mask.Empty() {
+		sanitizeScores(&result) // 透明部分过多时，纯色区域的匹配度可能是NaN
+	}
+
+	// 依次取出最高的匹配度，并抑制其附近的位置，避免同一目标被重复匹配
+	var boxes []image.Rectangle
+	var scores []float32
+	bounds := image.Rect(0, 0, result.Cols(), result.Rows())
+	for range maxTemplateMatches {
+		_, maxVal, _, maxLoc := gocv.MinMaxLoc(result)
+		if maxVal < scoreThreshold {
+			break
+		}
+		boxes = append(boxes, image.Rect(maxLoc.X, maxLoc.Y, maxLoc.X+w, maxLoc.Y+h))
+		scores = append(scores, maxVal)
+
+		near := image.Rect(maxLoc.X-w/2, maxLoc.Y-h/2, maxLoc.X+w/2+1, maxLoc.Y+h/2+1).Intersect(bounds)
+		region := result.Region(near)
+		region.SetTo(gocv.NewScalar(-1, 0, 0, 0))
+		region.Close()
+	}
+	return boxes, scores, nil
+}
+
+// 将NaN、无穷大等无效的匹配度置为-1
+func sanitizeScores(result *gocv.Mat) {
+	valid := gocv.NewMat()
+	defer valid.Close()
+	gocv.InRangeWithScalar(*result, gocv.NewScalar(-1, 0, 0, 0), gocv.NewScalar(1, 0, 0, 0), &valid)
+
+	clean := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(-1, 0, 0, 0), result.Rows(), result.Cols(), result.Type())
+	defer clean.Close()
+	result.CopyToWithMask(&clean, valid)
+	clean.CopyTo(result)
 }
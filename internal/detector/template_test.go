@@ -0,0 +1,88 @@
+package detector
+
+import (
+	"image"
+	"io/fs"
+	"os"
+	"slices"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// 按透明通道将 PNG 图标绘制到 img 的 at 处，swap 为 true 时交换红蓝通道
+func pasteIcon(t *testing.T, img *gocv.Mat, data []byte, at image.Point, swap bool) {
+	t.Helper()
+	icon, err := gocv.IMDecode(data, gocv.IMReadUnchanged)
+	if err != nil || icon.Channels() != 4 {
+		t.Fatalf("解码图标失败: %v", err)
+	}
+	defer icon.Close()
+
+	bgr := gocv.NewMat()
+	defer bgr.Close()
+	code := gocv.ColorBGRAToBGR
+	if swap {
+		code = gocv.ColorBGRAToRGB
+	}
+	gocv.CvtColor(icon, &bgr, code)
+
+	channels := gocv.Split(icon)
+	defer func() {
+		for _, c := range channels {
+			c.Close()
+		}
+	}()
+	dst := img.Region(image.Rect(at.X, at.Y, at.X+icon.Cols(), at.Y+icon.Rows()))
+	defer dst.Close()
+	bgr.CopyToWithMask(&dst, channels[3])
+}
+
+func TestTemplateDetector(t *testing.T) {
+	// 示例模板: 红色圆环、白色圆心、四周透明
+	fsys := os.DirFS("testdata")
+	d, err := NewTemplateDetector(fsys, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if !slices.Contains(d.Names(), "example") {
+		t.Fatalf("模板 = %v, 缺少 example", d.Names())
+	}
+
+	data, err := fs.ReadFile(fsys, "templates/example.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 灰色背景上两个相同的图标，以及一个红蓝互换的图标
+	img := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(90, 90, 90, 0), 60, 140, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	pasteIcon(t, &img, data, image.Pt(10, 10), false)
+	pasteIcon(t, &img, data, image.Pt(60, 30), false)
+	pasteIcon(t, &img, data, image.Pt(100, 10), true)
+
+	list, err := d.Match(NewTemplateDetectParam(img, "example", 0.9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []image.Rectangle
+	for _, det := range list {
+		got = append(got, det.Rect)
+		if det.Label != "example" || det.Source != SourceTemplate || det.Score < 0.99 {
+			t.Errorf("识别结果 = %+v", det)
+		}
+	}
+	slices.SortFunc(got, func(a, b image.Rectangle) int {
+		return a.Min.X - b.Min.X
+	})
+	// 通道顺序错误时，红蓝互换的图标会被识别而另外两个不会
+	want := []image.Rectangle{image.Rect(10, 10, 26, 26), image.Rect(60, 30, 76, 46)}
+	if !slices.Equal(got, want) {
+		t.Errorf("识别到的位置 = %v, 期望 %v", got, want)
+	}
+
+	if _, err := d.Match(NewTemplateDetectParam(img, "missing", 0.9)); err == nil {
+		t.Error("不存在的模板未返回错误")
+	}
+}
@@ -0,0 +1,208 @@
+package detector
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// 副本时间的识别结果
+type TimerReading struct {
+	Text       string // 识别出的时间，例如: 12:34
+	Duration   time.Duration
+	Confidence float64 // 0~1，各个数字匹配度中的最小值
+}
+
+// 识别游戏中的计时器，img 需是只包含时间文字的区域
+type TimerReader interface {
+	Read(img gocv.Mat) (TimerReading, bool)
+}
+
+type TimerReaderImpl struct {
+	digits [10]gocv.Mat // 二值化的数字模板，白色为数字
+	size   image.Point  // 模板的大小，所有模板大小一致
+	text   HSVRange     // 时间文字的颜色
+}
+
+/**
+ * 从 dir 目录加载数字模板 0.png ~ 9.png
+ * 模板为截取自游戏画面的单个数字，白色为数字、黑色为背景，高度与数字一致，所有模板大小相同
+ * @param text 时间文字的颜色范围，用于从画面中分离出数字
+ */
+func NewTimerReader(fsys fs.FS, dir string, text HSVRange) (TimerReader, error) {
+	if err := text.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &TimerReaderImpl{text: text}
+	for i := range r.digits {
+		name := path.Join(dir, strconv.Itoa(i)+".png")
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			r.close(i)
+			return nil, fmt.Errorf("读取数字模板失败: %w", err)
+		}
+		img, err := gocv.IMDecode(data, gocv.IMReadGrayScale)
+		if err != nil || img.Empty() {
+			img.Close()
+			r.close(i)
+			return nil, fmt.Errorf("无法加载数字模板: %s", name)
+		}
+
+		size := image.Pt(img.Cols(), img.Rows())
+		if i == 0 {
+			r.size = size
+		} else if size != r.size {
+			img.Close()
+			r.close(i)
+			return nil, fmt.Errorf("数字模板 %s 的大小 %v 与其他模板 %v 不一致", name, size, r.size)
+		}
+		r.digits[i] = gocv.NewMat()
+		gocv.Threshold(img, &r.digits[i], 127, 255, gocv.ThresholdBinary)
+		img.Close()
+	}
+	return r, nil
+}
+
+// 关闭前 n 个已加载的模板
+func (r *TimerReaderImpl) close(n int) {
+	for i := range n {
+		r.digits[i].Close()
+	}
+}
+
+func (r *TimerReaderImpl) Read(img gocv.Mat) (TimerReading, bool) {
+	if img.Empty() {
+		return TimerReading{}, false
+	}
+
+	hsv := gocv.NewMat()
+	mask := gocv.NewMat()
+	defer hsv.Close()
+	defer mask.Close()
+	toHSV(img, &hsv)
+	r.text.mask(hsv, &mask)
+
+	glyphs := findGlyphs(mask)
+	if len(glyphs) == 0 {
+		return TimerReading{}, false
+	}
+
+	text, confidence := "", 1.0
+	for _, glyph := range glyphs {
+		digit, score := r.match(mask, glyph)
+		text += strconv.Itoa(digit)
+		confidence = min(confidence, score)
+	}
+
+	duration, ok := parseTimer(text)
+	if !ok {
+		return TimerReading{Text: text, Confidence: confidence}, false
+	}
+	return TimerReading{Text: formatTimer(duration), Duration: duration, Confidence: confidence}, true
+}
+
+// 找出各个数字所在的区域，冒号等高度明显较小的符号会被忽略
+func findGlyphs(mask gocv.Mat) []image.Rectangle {
+	contours := gocv.FindContours(mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	var rects []image.Rectangle
+	height := 0
+	for i := range contours.Size() {
+		rect := gocv.BoundingRect(contours.At(i))
+		rects = append(rects, rect)
+		height = max(height, rect.Dy())
+	}
+	slices.SortFunc(rects, func(a, b image.Rectangle) int {
+		return a.Min.X - b.Min.X
+	})
+
+	// 笔画断开的数字会被识别为上下多个区域，横向重叠的区域需要合并
+	var glyphs []image.Rectangle
+	for _, rect := range rects {
+		if n := len(glyphs); n > 0 && rect.Min.X < glyphs[n-1].Max.X {
+			glyphs[n-1] = glyphs[n-1].Union(rect)
+			continue
+		}
+		glyphs = append(glyphs, rect)
+	}
+	return slices.DeleteFunc(glyphs, func(rect image.Rectangle) bool {
+		return rect.Dy()*10 < height*6
+	})
+}
+
+// 按模板的宽高比截取数字，缩放为模板大小后比较重合度(交并比)
+func (r *TimerReaderImpl) match(mask gocv.Mat, glyph image.Rectangle) (int, float64) {
+	w := glyph.Dy() * r.size.X / r.size.Y
+	cx := (glyph.Min.X + glyph.Max.X) / 2
+	box := image.Rect(cx-w/2, glyph.Min.Y, cx-w/2+max(w, 1), glyph.Max.Y)
+
+	// 靠近边缘时超出的部分按背景处理
+	cell := gocv.Zeros(box.Dy(), box.Dx(), gocv.MatTypeCV8U)
+	defer cell.Close()
+	if visible := box.Intersect(image.Rect(0, 0, mask.Cols(), mask.Rows())); !visible.Empty() {
+		src := mask.Region(visible)
+		dst := cell.Region(visible.Sub(box.Min))
+		src.CopyTo(&dst)
+		src.Close()
+		dst.Close()
+	}
+
+	scaled := gocv.NewMat()
+	and := gocv.NewMat()
+	or := gocv.NewMat()
+	defer scaled.Close()
+	defer and.Close()
+	defer or.Close()
+	gocv.Resize(cell, &scaled, r.size, 0, 0, gocv.InterpolationNearestNeighbor)
+
+	best, bestScore := 0, -1.0
+	for digit, template := range r.digits {
+		gocv.BitwiseAnd(scaled, template, &and)
+		gocv.BitwiseOr(scaled, template, &or)
+		score := ratio(gocv.CountNonZero(and), gocv.CountNonZero(or))
+		if score > bestScore {
+			best, bestScore = digit, score
+		}
+	}
+	return best, bestScore
+}
+
+// 按数字个数解析时间，冒号不参与识别: mss、mmss、hmmss、hhmmss
+func parseTimer(digits string) (time.Duration, bool) {
+	if len(digits) < 3 || len(digits) > 6 {
+		return 0, false
+	}
+
+	var parts []int
+	for end := len(digits); end > 0; end -= 2 {
+		n, _ := strconv.Atoi(digits[max(0, end-2):end])
+		parts = append(parts, n)
+	}
+	seconds, minutes, hours := parts[0], parts[1], 0
+	if len(parts) > 2 {
+		hours = parts[2]
+		if minutes >= 60 {
+			return 0, false
+		}
+	}
+	if seconds >= 60 {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second, true
+}
+
+func formatTimer(d time.Duration) string {
+	seconds := int(d.Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,114 @@
+package detector
+
+import (
+	"image"
+	"slices"
+	"star-map-tool/assets"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+func TestParseTimer(t *testing.T) {
+	cases := []struct {
+		digits string
+		want   time.Duration
+		ok     bool
+	}{
+		{"034", 34 * time.Second, true},
+		{"1234", 12*time.Minute + 34*time.Second, true},
+		{"12345", time.Hour + 23*time.Minute + 45*time.Second, true},
+		{"012345", time.Hour + 23*time.Minute + 45*time.Second, true},
+		{"99", 0, false},      // 数字过少
+		{"1234567", 0, false}, // 数字过多
+		{"1260", 0, false},    // 秒数超出范围
+		{"1599", 0, false},
+		{"16000", 0, false}, // 有小时时分钟数超出范围
+	}
+	for _, c := range cases {
+		got, ok := parseTimer(c.digits)
+		if ok != c.ok || got != c.want {
+			t.Errorf("parseTimer(%q) = %v %v, 期望 %v %v", c.digits, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestFormatTimer(t *testing.T) {
+	cases := map[time.Duration]string{
+		34 * time.Second:                            "00:34",
+		12*time.Minute + 34*time.Second:             "12:34",
+		time.Hour + 23*time.Minute + 45*time.Second: "1:23:45",
+	}
+	for d, want := range cases {
+		if got := formatTimer(d); got != want {
+			t.Errorf("formatTimer(%v) = %s, 期望 %s", d, got, want)
+		}
+	}
+}
+
+func fillRect(mask gocv.Mat, rect image.Rectangle) {
+	region := mask.Region(rect)
+	region.SetTo(gocv.NewScalar(255, 255, 255, 0))
+	region.Close()
+}
+
+func TestFindGlyphs(t *testing.T) {
+	mask := gocv.Zeros(30, 60, gocv.MatTypeCV8U)
+	defer mask.Close()
+
+	fillRect(mask, image.Rect(2, 5, 10, 25))
+	// 笔画断开的数字，上下两部分应合并
+	fillRect(mask, image.Rect(14, 5, 22, 14))
+	fillRect(mask, image.Rect(15, 16, 21, 25))
+	// 冒号，高度过小应被忽略
+	fillRect(mask, image.Rect(25, 10, 27, 12))
+	fillRect(mask, image.Rect(25, 18, 27, 20))
+	fillRect(mask, image.Rect(30, 5, 38, 25))
+
+	want := []image.Rectangle{
+		image.Rect(2, 5, 10, 25),
+		image.Rect(14, 5, 22, 25),
+		image.Rect(30, 5, 38, 25),
+	}
+	if got := findGlyphs(mask); !slices.Equal(got, want) {
+		t.Errorf("findGlyphs = %v, 期望 %v", got, want)
+	}
+}
+
+func TestTimerReader(t *testing.T) {
+	white := MustHSVRange(HSV{H: 0, S: 0, V: 200}, HSV{H: MaxHue, S: 60, V: MaxValue})
+	reader, err := NewTimerReader(assets.Digits, "digits", white)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 用数字模板拼出黑底白字的 12:34
+	img := gocv.Zeros(20, 70, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	x := 2
+	for _, c := range "12:34" {
+		if c == ':' {
+			fillRect(img, image.Rect(x, 8, x+2, 10))
+			fillRect(img, image.Rect(x, 14, x+2, 16))
+			x += 6
+			continue
+		}
+		digit := reader.(*TimerReaderImpl).digits[c-'0']
+		bgr := gocv.NewMat()
+		gocv.CvtColor(digit, &bgr, gocv.ColorGrayToBGR)
+		dst := img.Region(image.Rect(x, 3, x+digit.Cols(), 3+digit.Rows()))
+		bgr.CopyTo(&dst)
+		dst.Close()
+		bgr.Close()
+		x += digit.Cols() + 4
+	}
+
+	reading, ok := reader.Read(img)
+	if !ok || reading.Text != "12:34" || reading.Duration != 12*time.Minute+34*time.Second {
+		t.Fatalf("Read = %+v %v, 期望 12:34", reading, ok)
+	}
+	if reading.Confidence < 0.99 {
+		t.Errorf("可信度 = %v, 期望接近1", reading.Confidence)
+	}
+}
@@ -0,0 +1,187 @@
+package detector
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// 模型输出的格式，对应 model.yaml 中的 format
+const (
+	FormatYOLOv5 = "yolov5" // [1, N, 5+类别数]: cx, cy, w, h, 物体置信度, 各类别置信度
+	FormatYOLOv8 = "yolov8" // [1, 4+类别数, N]: cx, cy, w, h, 各类别置信度（YOLOv11相同）
+	FormatE2E    = "e2e"    // [1, N, 6]: x1, y1, x2, y2, 置信度, 类别（已在模型内完成NMS）
+)
+
+// 解码后的候选框，坐标为模型输入图像中的坐标
+type yoloBox struct {
+	x1, y1, x2, y2 float32
+	score          float32
+	classId        int
+}
+
+// 将模型的输出张量解码为候选框，shape 为张量的形状，丢弃置信度低于 scoreThreshold 的结果
+type yoloDecoder func(data []float32, shape []int, scoreThreshold float32) ([]yoloBox, error)
+
+func getYOLODecoder(format string) (yoloDecoder, error) {
+	switch format {
+	case FormatYOLOv5:
+		return decodeYOLOv5, nil
+	case FormatYOLOv8, "yolov11", "":
+		return decodeYOLOv8, nil
+	case FormatE2E:
+		return decodeE2E, nil
+	default:
+		return nil, fmt.Errorf("不支持的模型输出格式: %s", format)
+	}
+}
+
+// 去掉批次维度，返回二维形状
+func yoloShape(shape []int) (int, int, error) {
+	if len(shape) == 3 && shape[0] == 1 {
+		shape = shape[1:]
+	}
+	if len(shape) != 2 {
+		return 0, 0, fmt.Errorf("模型输出的形状错误: %v", shape)
+	}
+	return shape[0], shape[1], nil
+}
+
+func centerBox(cx, cy, w, h float32) (float32, float32, float32, float32) {
+	return cx - w/2, cy - h/2, cx + w/2, cy + h/2
+}
+
+func decodeYOLOv5(data []float32, shape []int, scoreThreshold float32) ([]yoloBox, error) {
+	rows, cols, err := yoloShape(shape)
+	if err != nil {
+		return nil, err
+	}
+	if cols < 6 || len(data) < rows*cols {
+		return nil, fmt.Errorf("YOLOv5 输出的形状错误: %v", shape)
+	}
+
+	var boxes []yoloBox
+	for i := range rows {
+		row := data[i*cols : (i+1)*cols]
+		objectness := row[4]
+		if objectness < scoreThreshold {
+			continue
+		}
+
+		classId, score := 0, row[5]
+		for c := 6; c < cols; c++ {
+			if row[c] > score {
+				classId, score = c-5, row[c]
+			}
+		}
+		if score *= objectness; score < scoreThreshold {
+			continue
+		}
+
+		x1, y1, x2, y2 := centerBox(row[0], row[1], row[2], row[3])
+		boxes = append(boxes, yoloBox{x1, y1, x2, y2, score, classId})
+	}
+	return boxes, nil
+}
+
+func decodeYOLOv8(data []float32, shape []int, scoreThreshold float32) ([]yoloBox, error) {
+	channels, anchors, err := yoloShape(shape)
+	if err != nil {
+		return nil, err
+	}
+	if channels < 5 || len(data) < channels*anchors {
+		return nil, fmt.Errorf("YOLOv8 输出的形状错误: %v", shape)
+	}
+
+	// 按通道存储，第 c 个通道的第 i 个值位于 data[c*anchors+i]
+	at := func(c, i int) float32 {
+		return data[c*anchors+i]
+	}
+
+	var boxes []yoloBox
+	for i := range anchors {
+		classId, score := 0, at(4, i)
+		for c := 5; c < channels; c++ {
+			if v := at(c, i); v > score {
+				classId, score = c-4, v
+			}
+		}
+		if score < scoreThreshold {
+			continue
+		}
+
+		x1, y1, x2, y2 := centerBox(at(0, i), at(1, i), at(2, i), at(3, i))
+		boxes = append(boxes, yoloBox{x1, y1, x2, y2, score, classId})
+	}
+	return boxes, nil
+}
+
+func decodeE2E(data []float32, shape []int, scoreThreshold float32) ([]yoloBox, error) {
+	rows, cols, err := yoloShape(shape)
+	if err != nil {
+		return nil, err
+	}
+	if cols < 6 || len(data) < rows*cols {
+		return nil, fmt.Errorf("端到端模型输出的形状错误: %v", shape)
+	}
+
+	var boxes []yoloBox
+	for i := range rows {
+		row := data[i*cols : (i+1)*cols]
+		if row[4] < scoreThreshold {
+			continue
+		}
+		boxes = append(boxes, yoloBox{row[0], row[1], row[2], row[3], row[4], int(row[5])})
+	}
+	return boxes, nil
+}
+
+// 等比缩放并在两侧填充，使图像不变形地放入模型的正方形输入中
+type letterbox struct {
+	scale      float64 // 原图到模型输入的缩放比例
+	padX, padY int     // 左侧、上方的填充
+	w, h       int     // 原图大小
+}
+
+func newLetterbox(w, h, size int) letterbox {
+	l := letterbox{scale: math.Min(float64(size)/float64(w), float64(size)/float64(h)), w: w, h: h}
+	nw, nh := l.resized()
+	l.padX, l.padY = (size-nw)/2, (size-nh)/2
+	return l
+}
+
+// 原图缩放后的大小
+func (l letterbox) resized() (int, int) {
+	return int(math.Round(float64(l.w) * l.scale)), int(math.Round(float64(l.h) * l.scale))
+}
+
+// 返回填充后的图像，调用层必须要关闭
+func (l letterbox) apply(img gocv.Mat, size int) (gocv.Mat, error) {
+	nw, nh := l.resized()
+	resized := gocv.NewMat()
+	defer resized.Close()
+	if err := gocv.Resize(img, &resized, image.Pt(nw, nh), 0, 0, gocv.InterpolationLinear); err != nil {
+		return gocv.NewMat(), err
+	}
+
+	padded := gocv.NewMat()
+	gray := color.RGBA{114, 114, 114, 0} // 与训练时的填充颜色一致
+	err := gocv.CopyMakeBorder(resized, &padded, l.padY, size-nh-l.padY, l.padX, size-nw-l.padX, gocv.BorderConstant, gray)
+	if err != nil {
+		padded.Close()
+		return gocv.NewMat(), err
+	}
+	return padded, nil
+}
+
+// 将模型输入中的坐标还原为原图中的坐标
+func (l letterbox) unmap(b yoloBox) image.Rectangle {
+	restore := func(v float32, pad int, limit int) int {
+		p := int(math.Round((float64(v) - float64(pad)) / l.scale))
+		return max(0, min(p, limit))
+	}
+	return image.Rect(restore(b.x1, l.padX, l.w), restore(b.y1, l.padY, l.h), restore(b.x2, l.padX, l.w), restore(b.y2, l.padY, l.h))
+}
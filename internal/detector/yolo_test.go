@@ -0,0 +1,138 @@
+package detector
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestDecodeYOLOv5(t *testing.T) {
+	// 3个候选框，2个类别: cx, cy, w, h, 物体置信度, 类别0, 类别1
+	data := []float32{
+		50, 50, 20, 10, 0.9, 0.8, 0.1, // 类别0 胜出
+		100, 80, 40, 40, 0.8, 0.2, 0.9, // 类别1 胜出
+		10, 10, 4, 4, 0.1, 0.9, 0.9, // 物体置信度过低
+	}
+	boxes, err := decodeYOLOv5(data, []int{1, 3, 7}, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 2 {
+		t.Fatalf("候选框数 = %d, 期望 2: %+v", len(boxes), boxes)
+	}
+
+	b := boxes[0]
+	if b.classId != 0 || !near(b.score, 0.72) || b.x1 != 40 || b.y1 != 45 || b.x2 != 60 || b.y2 != 55 {
+		t.Errorf("第1个候选框 = %+v", b)
+	}
+	if b := boxes[1]; b.classId != 1 || !near(b.score, 0.72) {
+		t.Errorf("第2个候选框 = %+v", b)
+	}
+
+	// 物体置信度通过，但与类别置信度相乘后低于阈值
+	boxes, _ = decodeYOLOv5([]float32{0, 0, 2, 2, 0.6, 0.6, 0}, []int{1, 7}, 0.5)
+	if len(boxes) != 0 {
+		t.Errorf("相乘后低于阈值的候选框未被丢弃: %+v", boxes)
+	}
+}
+
+func TestDecodeYOLOv8(t *testing.T) {
+	// 按通道存储的 [1, 6, 3]: cx, cy, w, h, 类别0, 类别1
+	data := []float32{
+		50, 100, 10, // cx
+		50, 80, 10, // cy
+		20, 40, 4, // w
+		10, 40, 4, // h
+		0.8, 0.2, 0.3, // 类别0
+		0.1, 0.9, 0.4, // 类别1
+	}
+	boxes, err := decodeYOLOv8(data, []int{1, 6, 3}, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 2 {
+		t.Fatalf("候选框数 = %d, 期望 2: %+v", len(boxes), boxes)
+	}
+	if b := boxes[0]; b.classId != 0 || !near(b.score, 0.8) || b.x1 != 40 || b.y1 != 45 || b.x2 != 60 || b.y2 != 55 {
+		t.Errorf("第1个候选框 = %+v", b)
+	}
+	if b := boxes[1]; b.classId != 1 || !near(b.score, 0.9) || b.x1 != 80 || b.y1 != 60 || b.x2 != 120 || b.y2 != 100 {
+		t.Errorf("第2个候选框 = %+v", b)
+	}
+}
+
+func TestDecodeE2E(t *testing.T) {
+	data := []float32{
+		10, 20, 30, 40, 0.9, 2,
+		1, 2, 3, 4, 0.3, 0,
+	}
+	boxes, err := decodeE2E(data, []int{1, 2, 6}, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := yoloBox{10, 20, 30, 40, 0.9, 2}
+	if len(boxes) != 1 || boxes[0] != want {
+		t.Errorf("候选框 = %+v, 期望 [%+v]", boxes, want)
+	}
+}
+
+func TestDecodeShapeError(t *testing.T) {
+	cases := []struct {
+		name   string
+		decode yoloDecoder
+		data   []float32
+		shape  []int
+	}{
+		{"v5 维度", decodeYOLOv5, make([]float32, 12), []int{2, 1, 6}},
+		{"v5 列数", decodeYOLOv5, make([]float32, 10), []int{1, 2, 5}},
+		{"v5 数据不足", decodeYOLOv5, make([]float32, 6), []int{1, 2, 6}},
+		{"v8 通道数", decodeYOLOv8, make([]float32, 8), []int{1, 4, 2}},
+		{"v8 数据不足", decodeYOLOv8, make([]float32, 5), []int{1, 5, 2}},
+		{"e2e 维度", decodeE2E, make([]float32, 6), []int{6}},
+		{"e2e 列数", decodeE2E, make([]float32, 5), []int{1, 1, 5}},
+	}
+	for _, c := range cases {
+		if _, err := c.decode(c.data, c.shape, 0.5); err == nil {
+			t.Errorf("%s: 形状 %v 未返回错误", c.name, c.shape)
+		}
+	}
+}
+
+func TestGetYOLODecoder(t *testing.T) {
+	for _, format := range []string{FormatYOLOv5, FormatYOLOv8, FormatE2E, "yolov11", ""} {
+		if _, err := getYOLODecoder(format); err != nil {
+			t.Errorf("%q: %v", format, err)
+		}
+	}
+	if _, err := getYOLODecoder("ssd"); err == nil {
+		t.Error("不支持的格式未返回错误")
+	}
+}
+
+func TestLetterbox(t *testing.T) {
+	l := newLetterbox(1280, 800, 1024)
+	if l.scale != 0.8 || l.padX != 0 || l.padY != 192 {
+		t.Fatalf("letterbox = %+v, 期望 scale 0.8 padX 0 padY 192", l)
+	}
+	if nw, nh := l.resized(); nw != 1024 || nh != 640 {
+		t.Fatalf("缩放后的大小 = %dx%d, 期望 1024x640", nw, nh)
+	}
+
+	cases := []struct {
+		box  yoloBox
+		want image.Rectangle
+	}{
+		{yoloBox{x1: 0, y1: 192, x2: 1024, y2: 832}, image.Rect(0, 0, 1280, 800)},     // 整个画面
+		{yoloBox{x1: 512, y1: 512, x2: 612, y2: 612}, image.Rect(640, 400, 765, 525)}, // 画面中间
+		{yoloBox{x1: -10, y1: 100, x2: 1100, y2: 900}, image.Rect(0, 0, 1280, 800)},   // 超出边缘
+	}
+	for _, c := range cases {
+		if got := l.unmap(c.box); got != c.want {
+			t.Errorf("unmap(%+v) = %v, 期望 %v", c.box, got, c.want)
+		}
+	}
+}
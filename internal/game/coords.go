@@ -0,0 +1,181 @@
+package game
+
+import (
+	"image"
+	"math"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// 参考分辨率，预设区域、点击位置等坐标都是在此大小的游戏窗口中测量的
+// 参考坐标的原点是窗口(含标题栏与边框)的左上角，换算时扣除边框后按客户区的大小缩放
+const (
+	ReferenceWidth  = 1280
+	ReferenceHeight = 800
+)
+
+func (r *GameRect) Width() int {
+	return r.w
+}
+
+func (r *GameRect) Height() int {
+	return r.h
+}
+
+func (r *GameRect) Insets() Insets {
+	return r.insets
+}
+
+// 整个窗口的大小
+func (r *GameRect) WindowSize() (int, int) {
+	return r.w + r.insets.Left + r.insets.Right, r.h + r.insets.Top + r.insets.Bottom
+}
+
+// 参考窗口的客户区大小，假定测量时的窗口边框与当前窗口一致
+func (r *GameRect) referenceClient() (int, int) {
+	return ReferenceWidth - r.insets.Left - r.insets.Right, ReferenceHeight - r.insets.Top - r.insets.Bottom
+}
+
+// 参考分辨率到游戏窗口实际大小的缩放比例
+func (r *GameRect) Scale() (float64, float64) {
+	rw, rh := r.referenceClient()
+	if r.w <= 0 || r.h <= 0 || rw <= 0 || rh <= 0 {
+		return 1, 1
+	}
+	return float64(r.w) / float64(rw), float64(r.h) / float64(rh)
+}
+
+// 参考坐标转换为屏幕坐标
+func (r *GameRect) ToScreen(x, y int) (int, int) {
+	sx, sy := r.Scale()
+	return r.x + int(math.Round(float64(x-r.insets.Left)*sx)), r.y + int(math.Round(float64(y-r.insets.Top)*sy))
+}
+
+// 屏幕坐标转换为参考坐标
+func (r *GameRect) FromScreen(x, y int) (int, int) {
+	sx, sy := r.Scale()
+	return int(math.Round(float64(x-r.x)/sx)) + r.insets.Left, int(math.Round(float64(y-r.y)/sy)) + r.insets.Top
+}
+
+// 参考坐标下的区域转换为屏幕坐标下的区域
+func (r *GameRect) ToScreenRect(rect image.Rectangle) image.Rectangle {
+	x0, y0 := r.ToScreen(rect.Min.X, rect.Min.Y)
+	x1, y1 := r.ToScreen(rect.Max.X, rect.Max.Y)
+	return image.Rect(x0, y0, x1, y1)
+}
+
+// 是否与参考分辨率一致，一致时不需要缩放截图
+func (r *GameRect) IsReference() bool {
+	w, h := r.WindowSize()
+	return w == ReferenceWidth && h == ReferenceHeight
+}
+
+// 截取参考坐标下的区域，并缩放为参考分辨率下的大小，使识别结果与阈值都不受窗口大小影响
+func (g *Game) captureReference(x, y, w, h int) (gocv.Mat, error) {
+	rect := g.Rect
+	if rect == nil {
+		return g.Screen.Capture(x, y, w, h)
+	}
+	if rect.IsReference() {
+		sx, sy := rect.ToScreen(x, y)
+		return g.Screen.Capture(sx, sy, w, h)
+	}
+
+	screen := rect.ToScreenRect(image.Rect(x, y, x+w, y+h))
+	img, err := g.Screen.Capture(screen.Min.X, screen.Min.Y, screen.Dx(), screen.Dy())
+	if err != nil || (img.Cols() == w && img.Rows() == h) {
+		return img, err
+	}
+	defer img.Close()
+
+	scaled := gocv.NewMat()
+	interpolation := gocv.InterpolationArea // 缩小时使用区域插值，避免细线条丢失
+	if img.Cols() < w {
+		interpolation = gocv.InterpolationLinear
+	}
+	if err := gocv.Resize(img, &scaled, image.Pt(w, h), 0, 0, interpolation); err != nil {
+		scaled.Close()
+		return gocv.NewMat(), err
+	}
+	return scaled, nil
+}
+
+// 将参考坐标转换为屏幕坐标的键鼠操作，策略中的坐标都是参考坐标
+type ScaledInput struct {
+	lock  sync.RWMutex
+	rect  *GameRect
+	input InputSink
+}
+
+func NewScaledInput(input InputSink) *ScaledInput {
+	return &ScaledInput{input: input}
+}
+
+// 游戏窗口位置变化后需要重新设置
+func (i *ScaledInput) SetRect(rect *GameRect) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.rect = rect
+}
+
+func (i *ScaledInput) toScreen(x, y int) (int, int) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	if i.rect == nil {
+		return x, y
+	}
+	return i.rect.ToScreen(x, y)
+}
+
+func (i *ScaledInput) KeyTap(key string) {
+	i.input.KeyTap(key)
+}
+
+func (i *ScaledInput) KeyDown(key string) {
+	i.input.KeyDown(key)
+}
+
+func (i *ScaledInput) KeyUp(key string) {
+	i.input.KeyUp(key)
+}
+
+func (i *ScaledInput) Location() (int, int) {
+	x, y := i.input.Location()
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	if i.rect == nil {
+		return x, y
+	}
+	return i.rect.FromScreen(x, y)
+}
+
+func (i *ScaledInput) Move(x, y int) {
+	i.input.Move(i.toScreen(x, y))
+}
+
+func (i *ScaledInput) MoveClick(x, y int) {
+	i.input.MoveClick(i.toScreen(x, y))
+}
+
+func (i *ScaledInput) Click() {
+	i.input.Click()
+}
+
+func (i *ScaledInput) MouseDown() {
+	i.input.MouseDown()
+}
+
+func (i *ScaledInput) MouseUp() {
+	i.input.MouseUp()
+}
+
+func (i *ScaledInput) DragSmooth(x, y int, speed float32) {
+	sx, sy := i.toScreen(x, y)
+	i.input.DragSmooth(sx, sy, speed)
+}
+
+func (i *ScaledInput) Scroll(x int, direction string) {
+	i.input.Scroll(x, direction)
+}
@@ -0,0 +1,55 @@
+package game
+
+import (
+	"image"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// 默认的截图间隔，间隔内的识别共用同一张截图
+const DefaultFrameInterval = 100 * time.Millisecond
+
+// 帧缓存，每隔 interval 截取一次整个游戏窗口，各个识别从中裁剪所需的区域(不复制数据)
+// 同一时刻的多个识别使用同一帧画面，结果相互一致，也减少了截图的次数
+type FrameCache struct {
+	interval time.Duration
+
+	lock  sync.Mutex
+	frame gocv.Mat  // 参考分辨率下的整个窗口(BGR)
+	at    time.Time // 截取的时间
+}
+
+func NewFrameCache(interval time.Duration) *FrameCache {
+	return &FrameCache{interval: interval, frame: gocv.NewMat()}
+}
+
+// 从当前帧中裁剪区域，当前帧已过期时先通过 capture 重新截取
+// 返回的区域与帧共享数据，调用层只读不写，使用后必须要关闭
+func (c *FrameCache) Region(rect image.Rectangle, capture func() (gocv.Mat, error)) (gocv.Mat, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.frame.Empty() || time.Since(c.at) >= c.interval {
+		frame, err := capture()
+		if err != nil {
+			return gocv.NewMat(), err
+		}
+		c.frame.Close() // 已裁剪出的区域仍持有数据的引用，不受影响
+		c.frame, c.at = frame, time.Now()
+	}
+
+	bounds := image.Rect(0, 0, c.frame.Cols(), c.frame.Rows())
+	if rect = rect.Intersect(bounds); rect.Empty() {
+		return gocv.NewMat(), nil
+	}
+	return c.frame.Region(rect), nil
+}
+
+// 丢弃当前帧，下一次识别时重新截取
+func (c *FrameCache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.at = time.Time{}
+}
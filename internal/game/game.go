@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/go-vgo/robotgo"
-	"github.com/tailscale/win"
 	"gocv.io/x/gocv"
 )
 
@@ -16,14 +15,32 @@ type Game struct {
 	Name  string
 	Title string
 	Rect  *GameRect // 游戏窗口位置
+
+	Screen ScreenSource // 截图来源，默认使用 robotgo，坐标为屏幕坐标
+	Input  InputSink    // 键鼠操作，默认使用 robotgo，坐标为参考分辨率下的坐标
+
+	Recorder *Recorder // 开启录制后不为空
+
+	scaled *ScaledInput // 窗口位置变化时需要同步
+	frames *FrameCache  // 为空时每次识别都单独截图
 }
 
-// 原点(0, 0)是屏幕左上角
+// 游戏窗口客户区(不含标题栏与边框)的位置，原点(0, 0)是屏幕左上角
 type GameRect struct {
 	x int
 	y int
 	w int
 	h int
+
+	insets Insets // 标题栏与边框
+}
+
+// 窗口边框相对客户区的宽度
+type Insets struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Right  int `json:"right"`
+	Bottom int `json:"bottom"`
 }
 
 var MoveKeys = []string{"w", "a", "s", "d", "ctrl", "shift"}
@@ -36,18 +53,45 @@ func NewGame(name string, title string) (*Game, error) {
 		return nil, errors.New("游戏进程名称不能为空")
 	}
 
-	g := &Game{Name: name, Title: title}
+	scaled := NewScaledInput(NewRobotgoInput())
+	g := &Game{Name: name, Title: title, Screen: NewRobotgoScreen(), Input: scaled, scaled: scaled}
+	g.SetFrameInterval(DefaultFrameInterval)
 	return g, nil
 }
 
+// 使用录制的画面代替游戏窗口，不会产生真实的键鼠操作，也不需要初始化
+func NewReplayGame(source *ReplaySource) *Game {
+	bounds := source.Bounds()
+	g := &Game{
+		Name:   "replay",
+		Title:  "回放",
+		Rect:   &GameRect{x: bounds.Min.X, y: bounds.Min.Y, w: bounds.Dx(), h: bounds.Dy(), insets: source.Insets()},
+		Screen: source,
+		Input:  NewNopInput(),
+	}
+	g.SetFrameInterval(DefaultFrameInterval)
+	return g
+}
+
+// 设置截图间隔，间隔内的识别共用同一张截图，0代表每次识别都单独截图
+func (g *Game) SetFrameInterval(interval time.Duration) {
+	if interval <= 0 {
+		g.frames = nil
+		return
+	}
+	g.frames = NewFrameCache(interval)
+}
+
+// 录制每一轮截取的画面与键鼠操作，保存到 root 目录下
+func (g *Game) Record(root string) *Recorder {
+	g.Recorder = NewRecorder(root, g.Screen, g.Input)
+	g.Screen, g.Input = g.Recorder, g.Recorder
+	return g.Recorder
+}
+
 func (g *Game) Initialize() bool {
-	robotgo.MouseSleep = 200
-	robotgo.KeySleep = 100
 	robotgo.Process()
 
-	var width int32 = 1280
-	var height int32 = 800
-
 	_, err := g.GetPid()
 	if err != nil {
 		return false
@@ -56,13 +100,26 @@ func (g *Game) Initialize() bool {
 
 	g.Active()
 	time.Sleep(time.Duration(1) * time.Second)
-	g.GetRect()
-	g.Resize(width, height)
+
+	screenWidth, screenHeight := robotgo.GetScreenSize()
+	rect := g.Rect
+	sx, sy := rect.Scale()
+	fmt.Printf("[初始器] 当前屏幕分辨率: %d x %d 游戏窗口位置: (%d, %d) 客户区大小: %d x %d 边框: %v\n",
+		screenWidth, screenHeight, rect.x, rect.y, rect.w, rect.h, rect.insets)
+	fmt.Printf("[初始器] 相对%dx%d窗口的缩放比例: %.2f x %.2f\n", ReferenceWidth, ReferenceHeight, sx, sy)
+	if w, h := rect.WindowSize(); w*ReferenceHeight != h*ReferenceWidth {
+		fmt.Printf("[初始器] 游戏窗口与%dx%d的宽高比不一致, 界面布局可能存在偏差, 推荐使用16:10的窗口\n", ReferenceWidth, ReferenceHeight)
+	}
 	return true
 }
 
+// 激活游戏窗口并重新获取窗口位置，窗口可以放在屏幕的任意位置
 func (g *Game) Active() {
+	if g.Pid == 0 {
+		return // 回放时没有游戏进程
+	}
 	robotgo.ActivePid(g.Pid)
+	g.refreshRect()
 }
 
 func (g *Game) GetPid() (int, error) {
@@ -86,59 +143,52 @@ func (g *Game) GetRect() (*GameRect, error) {
 	if g.Rect != nil {
 		return g.Rect, nil
 	}
-	x, y, w, h := robotgo.GetBounds(g.Pid)
+	wx, wy, ww, wh := robotgo.GetBounds(g.Pid) // 整个窗口
+	x, y, w, h := robotgo.GetClient(g.Pid)     // 客户区
+	if w <= 0 || h <= 0 {
+		x, y, w, h = wx, wy, ww, wh // 获取不到客户区时按无边框窗口处理
+	}
 
 	rect := &GameRect{
 		x: x, y: y, w: w, h: h,
+		insets: Insets{Left: x - wx, Top: y - wy, Right: wx + ww - x - w, Bottom: wy + wh - y - h},
 	}
 	g.Rect = rect
+	if g.scaled != nil {
+		g.scaled.SetRect(rect)
+	}
 	return rect, nil
 }
 
-func (g *Game) GetScreenshot(args ...int) image.Image {
+// 不传参数时截取整个游戏窗口，否则截取 x, y, w, h 指定的区域
+// 坐标为参考分辨率下的坐标，截图会缩放为参考分辨率下的大小
+// 开启帧缓存时返回的是缓存帧中的区域，调用层只能读取不能修改
+func (g *Game) GetScreenshotMatRGB(args ...int) (gocv.Mat, error) {
 	length := len(args)
 	if !(length == 0 || length == 4) {
 		panic("参数数量错误!")
 	}
 
-	rect := g.Rect
-	var bitmap robotgo.CBitmap
-	if length == 0 {
-		bitmap = robotgo.CaptureScreen(rect.x, rect.y, rect.w, rect.h)
-	} else {
-		bitmap = robotgo.CaptureScreen(args[0], args[1], args[2], args[3])
+	rect := image.Rect(0, 0, ReferenceWidth, ReferenceHeight)
+	if length == 4 {
+		rect = image.Rect(args[0], args[1], args[0]+args[2], args[1]+args[3])
 	}
-	defer robotgo.FreeBitmap(bitmap)
-
-	image := robotgo.ToImage(bitmap)
-	return image
-}
-
-func (g *Game) GetScreenshotMatRGB(args ...int) (gocv.Mat, error) {
-	image := g.GetScreenshot(args...)
-
-	mat, err := gocv.ImageToMatRGB(image) // 调用层必须要关闭，不然会内存泄露
-	return mat, err                       // 选择直接值传递回去
+	if g.frames == nil {
+		return g.captureReference(rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy()) // 调用层必须要关闭，不然会内存泄露
+	}
+	return g.frames.Region(rect, func() (gocv.Mat, error) {
+		return g.captureReference(0, 0, ReferenceWidth, ReferenceHeight)
+	})
 }
 
-func (g *Game) Resize(w int32, h int32) bool {
-	screenWidth, screenHeight := robotgo.GetScreenSize()
-	rect := g.Rect
-	fmt.Printf("[初始器] 当前屏幕分辨率: %d x %d 游戏窗口大小: %d x %d\n", screenWidth, screenHeight, rect.w, rect.h)
-
-	hwnd := robotgo.FindWindow(g.Title)
-	val := win.SetWindowPos(hwnd, win.HWND_TOP, 0, 0, w, h, win.SWP_SHOWWINDOW)
-	if !val {
-		panic("调整游戏窗口大小失败")
+// 松开所有移动相关的按键
+func (g *Game) ReleaseAllKey() {
+	for _, key := range MoveKeys {
+		g.Input.KeyUp(key)
 	}
-
-	g.refreshRect()
-
-	rect = g.Rect
-	fmt.Printf("[初始器] 变更后游戏窗口大小: %d x %d\n", rect.w, rect.h)
-	return val
 }
 
+// 未创建游戏对象时（例如异常退出）使用 robotgo 松开按键
 func ReleaseAllKey() {
 	for _, key := range MoveKeys {
 		robotgo.KeyUp(key)
@@ -148,6 +198,9 @@ func ReleaseAllKey() {
 func (g *Game) refreshRect() error {
 	g.Rect = nil
 	g.GetRect()
+	if g.frames != nil {
+		g.frames.Invalidate() // 窗口可能已移动
+	}
 
 	return nil
 }
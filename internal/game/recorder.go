@@ -0,0 +1,225 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// 录制文件的清单，与截图一起保存在每一轮的录制目录中
+const RecordManifestName = "manifest.json"
+
+type RecordManifest struct {
+	Name   string          `json:"name"`
+	Start  time.Time       `json:"start"`
+	Result string          `json:"result,omitempty"` // 本轮结果
+	Window RecordRect      `json:"window"`           // 开始录制时的游戏窗口客户区位置
+	Insets Insets          `json:"insets"`           // 开始录制时的窗口边框
+	Frames []RecordedFrame `json:"frames"`
+	Events []RecordedEvent `json:"events"`
+}
+
+type RecordRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// 一次截图，坐标原点是屏幕左上角
+type RecordedFrame struct {
+	At   int64  `json:"at"` // 距开始录制的毫秒数
+	File string `json:"file"`
+	RecordRect
+}
+
+// 一次键鼠操作，坐标为参考分辨率下的坐标
+type RecordedEvent struct {
+	At        int64   `json:"at"` // 距开始录制的毫秒数
+	Type      string  `json:"type"`
+	Key       string  `json:"key,omitempty"`
+	X         int     `json:"x,omitempty"`
+	Y         int     `json:"y,omitempty"`
+	Speed     float32 `json:"speed,omitempty"`
+	Direction string  `json:"direction,omitempty"`
+}
+
+// 录制器，包装截图来源与键鼠操作，记录每一轮截取的画面与执行的操作
+// 未开始录制时直接转发给被包装的对象
+type Recorder struct {
+	screen ScreenSource
+	input  InputSink
+	root   string // 录制文件的根目录，每一轮在其中创建一个子目录
+
+	lock     sync.Mutex
+	dir      string // 当前轮次的录制目录，为空代表未在录制
+	start    time.Time
+	manifest RecordManifest
+	writes   chan recordWrite // 异步保存截图，避免拖慢识别
+	done     chan struct{}
+}
+
+type recordWrite struct {
+	path string
+	img  gocv.Mat
+}
+
+func NewRecorder(root string, screen ScreenSource, input InputSink) *Recorder {
+	return &Recorder{root: root, screen: screen, input: input}
+}
+
+// 开始录制新的一轮，rect 为当前的游戏窗口位置
+func (r *Recorder) Begin(name string, rect *GameRect) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.dir != "" {
+		return fmt.Errorf("录制 %s 尚未结束", r.manifest.Name)
+	}
+
+	dir := filepath.Join(r.root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建录制目录失败: %w", err)
+	}
+
+	r.dir, r.start = dir, time.Now()
+	r.manifest = RecordManifest{Name: name, Start: r.start}
+	if rect != nil {
+		r.manifest.Window = RecordRect{X: rect.x, Y: rect.y, W: rect.w, H: rect.h}
+		r.manifest.Insets = rect.insets
+	}
+	r.writes, r.done = make(chan recordWrite, 64), make(chan struct{})
+	go r.write(r.writes, r.done)
+	return nil
+}
+
+// 结束本轮录制，等待截图保存完毕后写入清单
+func (r *Recorder) End(result string) error {
+	r.lock.Lock()
+	if r.dir == "" {
+		r.lock.Unlock()
+		return nil
+	}
+	dir, writes, done := r.dir, r.writes, r.done
+	manifest := r.manifest
+	manifest.Result = result
+	r.dir, r.writes, r.done = "", nil, nil
+	r.manifest = RecordManifest{}
+	r.lock.Unlock()
+
+	close(writes)
+	<-done
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, RecordManifestName), data, 0644); err != nil {
+		return fmt.Errorf("保存录制清单失败: %w", err)
+	}
+	log.Printf("[录制器] 已保存本轮录制: %s (截图%d张 操作%d次)\n", dir, len(manifest.Frames), len(manifest.Events))
+	return nil
+}
+
+func (r *Recorder) write(writes <-chan recordWrite, done chan<- struct{}) {
+	defer close(done)
+	for w := range writes {
+		if !gocv.IMWrite(w.path, w.img) {
+			log.Printf("[录制器] 保存截图 %s 失败\n", w.path)
+		}
+		w.img.Close()
+	}
+}
+
+func (r *Recorder) elapsed() int64 {
+	return time.Since(r.start).Milliseconds()
+}
+
+func (r *Recorder) Capture(x, y, w, h int) (gocv.Mat, error) {
+	img, err := r.screen.Capture(x, y, w, h)
+	if err != nil {
+		return img, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.dir == "" {
+		return img, nil
+	}
+	at := r.elapsed()
+	file := fmt.Sprintf("%08d-%05d.png", at, len(r.manifest.Frames))
+	r.manifest.Frames = append(r.manifest.Frames, RecordedFrame{
+		At: at, File: file, RecordRect: RecordRect{X: x, Y: y, W: img.Cols(), H: img.Rows()},
+	})
+	r.writes <- recordWrite{path: filepath.Join(r.dir, file), img: img.Clone()}
+	return img, nil
+}
+
+func (r *Recorder) record(event RecordedEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.dir == "" {
+		return
+	}
+	event.At = r.elapsed()
+	r.manifest.Events = append(r.manifest.Events, event)
+}
+
+func (r *Recorder) KeyTap(key string) {
+	r.record(RecordedEvent{Type: "keytap", Key: key})
+	r.input.KeyTap(key)
+}
+
+func (r *Recorder) KeyDown(key string) {
+	r.record(RecordedEvent{Type: "keydown", Key: key})
+	r.input.KeyDown(key)
+}
+
+func (r *Recorder) KeyUp(key string) {
+	r.record(RecordedEvent{Type: "keyup", Key: key})
+	r.input.KeyUp(key)
+}
+
+func (r *Recorder) Location() (int, int) {
+	return r.input.Location()
+}
+
+func (r *Recorder) Move(x, y int) {
+	r.record(RecordedEvent{Type: "move", X: x, Y: y})
+	r.input.Move(x, y)
+}
+
+func (r *Recorder) MoveClick(x, y int) {
+	r.record(RecordedEvent{Type: "moveclick", X: x, Y: y})
+	r.input.MoveClick(x, y)
+}
+
+func (r *Recorder) Click() {
+	r.record(RecordedEvent{Type: "click"})
+	r.input.Click()
+}
+
+func (r *Recorder) MouseDown() {
+	r.record(RecordedEvent{Type: "mousedown"})
+	r.input.MouseDown()
+}
+
+func (r *Recorder) MouseUp() {
+	r.record(RecordedEvent{Type: "mouseup"})
+	r.input.MouseUp()
+}
+
+func (r *Recorder) DragSmooth(x, y int, speed float32) {
+	r.record(RecordedEvent{Type: "drag", X: x, Y: y, Speed: speed})
+	r.input.DragSmooth(x, y, speed)
+}
+
+func (r *Recorder) Scroll(x int, direction string) {
+	r.record(RecordedEvent{Type: "scroll", X: x, Direction: direction})
+	r.input.Scroll(x, direction)
+}
@@ -0,0 +1,403 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// 回放的时钟，决定当前应当返回哪一帧
+type Clock interface {
+	Now() time.Duration // 距回放开始的时长
+}
+
+// 按真实时间推进，speed 为倍速
+type WallClock struct {
+	start time.Time
+	speed float64
+}
+
+func NewWallClock(speed float64) *WallClock {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &WallClock{start: time.Now(), speed: speed}
+}
+
+func (c *WallClock) Now() time.Duration {
+	return time.Duration(float64(time.Since(c.start)) * c.speed)
+}
+
+// 虚拟时钟，每读取一次前进 step，也可以通过 Advance 手动推进
+type StepClock struct {
+	lock sync.Mutex
+	now  time.Duration
+	step time.Duration
+}
+
+func NewStepClock(step time.Duration) *StepClock {
+	return &StepClock{step: step}
+}
+
+func (c *StepClock) Now() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now
+	c.now += c.step
+	return now
+}
+
+func (c *StepClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now += d
+}
+
+// 回放中的一帧（PNG目录 或 录制目录）
+type replayFrame struct {
+	at   time.Duration // 距第一帧的时长
+	path string
+	rect image.Rectangle // 录制的截图区域(屏幕坐标)，PNG目录中为空
+}
+
+// 文件名以毫秒时间戳开头，例如: 000012345.png、1718000000123_boss.png
+var replayFramePattern = regexp.MustCompile(`^(\d+)`)
+
+// 以录制的截图代替游戏画面，用于离线复现问题
+// 帧是从屏幕原点(0, 0)开始截取的画面，Capture 按屏幕坐标从中裁剪
+// 录制目录（包含 manifest.json）中的截图只是局部区域，按时间依次绘制到同一张画布上
+type ReplaySource struct {
+	lock  sync.Mutex
+	clock Clock
+
+	frames   []replayFrame      // PNG目录或录制目录中的帧，按时间排序
+	recorded bool               // 是否为录制目录
+	window   image.Rectangle    // 录制时的游戏窗口客户区位置
+	insets   Insets             // 录制时的窗口边框
+	video    *gocv.VideoCapture // 视频文件
+	fps      float64
+
+	origin image.Point // 帧左上角对应的屏幕坐标
+	frame  gocv.Mat    // 当前帧(BGR)
+	index  int         // 当前帧的序号，-1代表尚未读取
+}
+
+/**
+ * @param path PNG截图所在目录 或 视频文件
+ * @param clock 回放时钟，为空时按真实时间推进
+ */
+func NewReplaySource(path string, clock Clock) (*ReplaySource, error) {
+	if clock == nil {
+		clock = NewWallClock(1)
+	}
+	r := &ReplaySource{clock: clock, frame: gocv.NewMat(), index: -1}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取回放文件失败: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, RecordManifestName)); err == nil {
+		if err := r.loadRecorded(path); err != nil {
+			return nil, err
+		}
+	} else if info.IsDir() {
+		if r.frames, err = loadReplayFrames(path); err != nil {
+			return nil, err
+		}
+	} else {
+		if r.video, err = gocv.VideoCaptureFile(path); err != nil {
+			return nil, fmt.Errorf("打开回放视频失败: %w", err)
+		}
+		if r.fps = r.video.Get(gocv.VideoCaptureFPS); r.fps <= 0 {
+			r.fps = 30
+		}
+	}
+
+	// 预先读取第一帧，确认回放内容可用
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.seek(0); err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func loadReplayFrames(dir string) ([]replayFrame, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取回放目录失败: %w", err)
+	}
+
+	var frames []replayFrame
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(name), ".png") {
+			continue
+		}
+		match := replayFramePattern.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("回放截图 %s 的文件名需要以毫秒时间戳开头", name)
+		}
+		ms, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("回放截图 %s 的时间戳错误: %w", name, err)
+		}
+		frames = append(frames, replayFrame{at: time.Duration(ms) * time.Millisecond, path: filepath.Join(dir, name)})
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("回放目录 %s 中没有PNG截图", dir)
+	}
+
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].at < frames[j].at })
+	first := frames[0].at
+	for i := range frames {
+		frames[i].at -= first
+	}
+	return frames, nil
+}
+
+// 读取 Recorder 保存的录制目录，画布覆盖游戏窗口与所有截图区域
+func (r *ReplaySource) loadRecorded(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, RecordManifestName))
+	if err != nil {
+		return fmt.Errorf("读取录制清单失败: %w", err)
+	}
+	var manifest RecordManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("解析录制清单失败: %w", err)
+	}
+	if len(manifest.Frames) == 0 {
+		return fmt.Errorf("录制 %s 中没有截图", dir)
+	}
+
+	w := manifest.Window
+	r.window = image.Rect(w.X, w.Y, w.X+w.W, w.Y+w.H)
+	r.insets = manifest.Insets
+	bounds := r.window
+	for _, f := range manifest.Frames {
+		rect := image.Rect(f.X, f.Y, f.X+f.W, f.Y+f.H)
+		r.frames = append(r.frames, replayFrame{
+			at: time.Duration(f.At) * time.Millisecond, path: filepath.Join(dir, f.File), rect: rect,
+		})
+		bounds = bounds.Union(rect)
+	}
+	sort.SliceStable(r.frames, func(i, j int) bool { return r.frames[i].at < r.frames[j].at })
+
+	r.recorded = true
+	r.origin = bounds.Min
+	r.frame.Close()
+	r.frame = gocv.Zeros(bounds.Dy(), bounds.Dx(), gocv.MatTypeCV8UC3)
+	return nil
+}
+
+// 帧左上角对应的屏幕坐标，默认(0, 0)
+func (r *ReplaySource) SetOrigin(x, y int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.origin = image.Pt(x, y)
+}
+
+// 当前帧的宽高
+func (r *ReplaySource) Size() (int, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.frame.Cols(), r.frame.Rows()
+}
+
+// 录制时的窗口边框，非录制目录时按无边框窗口处理
+func (r *ReplaySource) Insets() Insets {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.insets
+}
+
+// 游戏窗口客户区的屏幕坐标，非录制目录时为整个画面
+func (r *ReplaySource) Bounds() image.Rectangle {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.recorded && !r.window.Empty() {
+		return r.window
+	}
+	return image.Rect(0, 0, r.frame.Cols(), r.frame.Rows()).Add(r.origin)
+}
+
+func (r *ReplaySource) Capture(x, y, w, h int) (gocv.Mat, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.seek(r.clock.Now()); err != nil {
+		return gocv.NewMat(), err
+	}
+
+	bounds := image.Rect(0, 0, r.frame.Cols(), r.frame.Rows())
+	region := image.Rect(x, y, x+w, y+h).Sub(r.origin).Intersect(bounds)
+	if region.Empty() {
+		return gocv.NewMat(), fmt.Errorf("截图区域(%d, %d, %d, %d)超出了回放画面", x, y, w, h)
+	}
+
+	crop := r.frame.Region(region)
+	defer crop.Close()
+	return crop.Clone(), nil // 调用层必须要关闭
+}
+
+// 切换到 now 时刻的帧，已到结尾时保持最后一帧
+func (r *ReplaySource) seek(now time.Duration) error {
+	if r.video != nil {
+		return r.seekVideo(int(now.Seconds() * r.fps))
+	}
+
+	target := sort.Search(len(r.frames), func(i int) bool { return r.frames[i].at > now }) - 1
+	if target < 0 {
+		target = 0
+	}
+	if target == r.index {
+		return nil
+	}
+	if r.recorded {
+		return r.seekRecorded(target)
+	}
+
+	img := gocv.IMRead(r.frames[target].path, gocv.IMReadColor)
+	defer img.Close()
+	if img.Empty() {
+		return fmt.Errorf("读取回放截图 %s 失败", r.frames[target].path)
+	}
+	if err := img.CopyTo(&r.frame); err != nil {
+		return err
+	}
+	r.index = target
+	return nil
+}
+
+// 依次绘制到 target 为止的截图，回退时从头开始绘制
+func (r *ReplaySource) seekRecorded(target int) error {
+	if target < r.index {
+		r.frame.SetTo(gocv.NewScalar(0, 0, 0, 0))
+		r.index = -1
+	}
+
+	bounds := image.Rect(0, 0, r.frame.Cols(), r.frame.Rows())
+	for i := r.index + 1; i <= target; i++ {
+		frame := r.frames[i]
+		img := gocv.IMRead(frame.path, gocv.IMReadColor)
+		if img.Empty() {
+			img.Close()
+			return fmt.Errorf("读取回放截图 %s 失败", frame.path)
+		}
+		rect := frame.rect.Sub(r.origin).Intersect(bounds)
+		rect = rect.Intersect(image.Rect(0, 0, img.Cols(), img.Rows()).Add(rect.Min))
+		if rect.Empty() {
+			img.Close()
+			r.index = i
+			continue
+		}
+
+		src := img.Region(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+		dst := r.frame.Region(rect)
+		err := src.CopyTo(&dst)
+		dst.Close()
+		src.Close()
+		img.Close()
+		if err != nil {
+			return err
+		}
+		r.index = i
+	}
+	return nil
+}
+
+func (r *ReplaySource) seekVideo(target int) error {
+	if target == r.index {
+		return nil
+	}
+	if target < r.index {
+		r.video.Set(gocv.VideoCapturePosFrames, float64(target))
+		r.index = target - 1
+	}
+
+	img, next := gocv.NewMat(), gocv.NewMat()
+	defer img.Close()
+	defer next.Close()
+	for r.index < target {
+		if ok := r.video.Read(&next); !ok || next.Empty() {
+			break // 已到结尾
+		}
+		img, next = next, img
+		r.index++
+	}
+	if img.Empty() {
+		if r.frame.Empty() {
+			return errors.New("回放视频中没有可用的画面")
+		}
+		return nil
+	}
+	return img.CopyTo(&r.frame)
+}
+
+func (r *ReplaySource) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.close()
+}
+
+func (r *ReplaySource) close() error {
+	var err error
+	if r.video != nil {
+		err = r.video.Close()
+		r.video = nil
+	}
+	r.frame.Close()
+	return err
+}
+
+// 回放时使用的空输入，只记录鼠标位置，不会产生真实的键鼠操作
+type NopInput struct {
+	lock sync.Mutex
+	x, y int
+}
+
+func NewNopInput() InputSink {
+	return &NopInput{}
+}
+
+func (i *NopInput) KeyTap(key string)  {}
+func (i *NopInput) KeyDown(key string) {}
+func (i *NopInput) KeyUp(key string)   {}
+
+func (i *NopInput) Location() (int, int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.x, i.y
+}
+
+func (i *NopInput) Move(x, y int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.x, i.y = x, y
+}
+
+func (i *NopInput) MoveClick(x, y int) {
+	i.Move(x, y)
+}
+
+func (i *NopInput) Click()     {}
+func (i *NopInput) MouseDown() {}
+func (i *NopInput) MouseUp()   {}
+
+func (i *NopInput) DragSmooth(x, y int, speed float32) {
+	i.Move(x, y)
+}
+
+func (i *NopInput) Scroll(x int, direction string) {}
@@ -0,0 +1,73 @@
+package game
+
+import (
+	"github.com/go-vgo/robotgo"
+	"gocv.io/x/gocv"
+)
+
+// 基于 robotgo 的屏幕截图
+type RobotgoScreen struct{}
+
+func NewRobotgoScreen() ScreenSource {
+	return &RobotgoScreen{}
+}
+
+func (s *RobotgoScreen) Capture(x, y, w, h int) (gocv.Mat, error) {
+	bitmap := robotgo.CaptureScreen(x, y, w, h)
+	defer robotgo.FreeBitmap(bitmap)
+
+	return gocv.ImageToMatRGB(robotgo.ToImage(bitmap))
+}
+
+// 基于 robotgo 的键盘鼠标操作
+type RobotgoInput struct{}
+
+func NewRobotgoInput() InputSink {
+	robotgo.MouseSleep = 200
+	robotgo.KeySleep = 100
+	return &RobotgoInput{}
+}
+
+func (i *RobotgoInput) KeyTap(key string) {
+	robotgo.KeyTap(key)
+}
+
+func (i *RobotgoInput) KeyDown(key string) {
+	robotgo.KeyDown(key)
+}
+
+func (i *RobotgoInput) KeyUp(key string) {
+	robotgo.KeyUp(key)
+}
+
+func (i *RobotgoInput) Location() (int, int) {
+	return robotgo.Location()
+}
+
+func (i *RobotgoInput) Move(x, y int) {
+	robotgo.Move(x, y)
+}
+
+func (i *RobotgoInput) MoveClick(x, y int) {
+	robotgo.MoveClick(x, y)
+}
+
+func (i *RobotgoInput) Click() {
+	robotgo.Click()
+}
+
+func (i *RobotgoInput) MouseDown() {
+	robotgo.Toggle("left")
+}
+
+func (i *RobotgoInput) MouseUp() {
+	robotgo.Toggle("left", "up")
+}
+
+func (i *RobotgoInput) DragSmooth(x, y int, speed float32) {
+	robotgo.DragSmooth(x, y, speed)
+}
+
+func (i *RobotgoInput) Scroll(x int, direction string) {
+	robotgo.ScrollDir(x, direction)
+}
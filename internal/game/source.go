@@ -0,0 +1,25 @@
+package game
+
+import "gocv.io/x/gocv"
+
+// 画面来源，坐标原点是屏幕左上角
+type ScreenSource interface {
+	// 截取指定区域，返回BGR格式的Mat(与 gocv.ImageToMatRGB 的结果一致)，调用层必须要关闭
+	Capture(x, y, w, h int) (gocv.Mat, error)
+}
+
+// 输入设备，负责键盘、鼠标与滚轮操作，坐标原点是屏幕左上角
+type InputSink interface {
+	KeyTap(key string)
+	KeyDown(key string)
+	KeyUp(key string)
+
+	Location() (int, int) // 鼠标当前位置
+	Move(x, y int)
+	MoveClick(x, y int)
+	Click()
+	MouseDown() // 按下鼠标左键
+	MouseUp()   // 松开鼠标左键
+	DragSmooth(x, y int, speed float32)
+	Scroll(x int, direction string)
+}
@@ -76,6 +76,18 @@ func (l *Listener) run0() {
 	<-signals
 }
 
+// 不等待F9，直接进入执行状态（状态控制器装载完成后才会生效）
+func (l *Listener) Trigger() {
+	for !atomic.CompareAndSwapInt32(&l.state, STATE_READY, STATE_RUNNING) {
+		if atomic.LoadInt32(&l.state) != STATE_CREATE {
+			return // 已经在执行或正在停止
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	l.Open <- 1
+	log.Println("[状态控制器] 已启用自动开始, 开始执行任务.")
+}
+
 func (l *Listener) Release() {
 	hook.End()
 	time.Sleep(1 * time.Second) // 很奇怪的东西，hook关闭不彻底会导致下次启动失败(重启进程也不行)
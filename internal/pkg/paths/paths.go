@@ -20,6 +20,28 @@ func GetAbsolutePath(relativePath string) string {
 	return filepath.Join(projectRoot, relativePath)
 }
 
+// 依次在工作目录、程序所在目录、项目根目录下查找文件，都不存在时返回工作目录下的路径
+func Lookup(relativePath string) string {
+	if filepath.IsAbs(relativePath) {
+		return relativePath
+	}
+
+	candidates := []string{relativePath}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), relativePath))
+	}
+	if projectRoot != "" || initProjectRoot() == nil {
+		candidates = append(candidates, filepath.Join(projectRoot, relativePath))
+	}
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return relativePath
+}
+
 func initProjectRoot() error {
 	_, filename, _, ok := runtime.Caller(0)
 	if !ok {
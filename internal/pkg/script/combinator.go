@@ -0,0 +1,109 @@
+package script
+
+import (
+	"context"
+	"log"
+	"star-map-tool/internal/pkg/sleeper"
+	"sync"
+	"time"
+)
+
+// 操作组合器：将多个操作组合为一个操作，用于声明式地描述重试、限时、补救等流程
+
+// 失败后等待 backoff 再重试，最多执行 n 次
+func Retry(op Operation, n int, backoff time.Duration) Operation {
+	return func(ctx context.Context) bool {
+		for i := range n {
+			if op(ctx) {
+				return true
+			}
+			if ctx.Err() != nil || i+1 == n {
+				break
+			}
+			log.Printf("[脚本] 操作执行失败, %v后进行第%d次重试\n", backoff, i+1)
+			if !sleeper.Sleep(ctx, int(backoff/time.Millisecond)) {
+				break
+			}
+		}
+		return false
+	}
+}
+
+// 限制操作的执行时长，超时后中断操作并视为失败
+func WithTimeout(op Operation, d time.Duration) Operation {
+	return func(ctx context.Context) bool {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+
+		return op(ctx) && ctx.Err() == nil
+	}
+}
+
+// primary 失败后执行 recovery，以 recovery 的结果为准（被中断时不执行 recovery）
+func Fallback(primary Operation, recovery Operation) Operation {
+	return func(ctx context.Context) bool {
+		if primary(ctx) {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		return recovery(ctx)
+	}
+}
+
+// 依次执行，任一操作失败即停止
+func Sequence(ops ...Operation) Operation {
+	return func(ctx context.Context) bool {
+		for _, op := range ops {
+			if ctx.Err() != nil || !op(ctx) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// 同时执行，任一操作成功即中断其余操作，全部失败时视为失败
+func Race(ops ...Operation) Operation {
+	return func(ctx context.Context) bool {
+		return concurrent(ctx, ops, true)
+	}
+}
+
+// 同时执行，任一操作失败即中断其余操作，全部成功时视为成功
+func Parallel(ops ...Operation) Operation {
+	return func(ctx context.Context) bool {
+		return concurrent(ctx, ops, false)
+	}
+}
+
+// 同时执行 ops，某个操作的结果等于 stopOn 时中断其余操作
+// 会等待所有操作返回后再结束，避免被中断的操作仍按着按键
+func concurrent(ctx context.Context, ops []Operation, stopOn bool) bool {
+	if len(ops) == 0 {
+		return !stopOn
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	result := !stopOn
+
+	var wg sync.WaitGroup
+	for _, op := range ops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if op(ctx) == stopOn {
+				once.Do(func() {
+					result = stopOn
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,161 @@
+package script
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 返回固定结果的操作，并记录执行次数
+func counted(result bool, calls *int32) Operation {
+	return func(ctx context.Context) bool {
+		atomic.AddInt32(calls, 1)
+		return result
+	}
+}
+
+// 阻塞直到 ctx 被取消，返回 false；cancelled 记录被中断的次数
+func blocking(cancelled *int32) Operation {
+	return func(ctx context.Context) bool {
+		<-ctx.Done()
+		atomic.AddInt32(cancelled, 1)
+		return false
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	if Retry(counted(false, &calls), 3, time.Millisecond)(context.Background()) {
+		t.Fatal("全部失败时应返回 false")
+	}
+	if calls != 3 {
+		t.Fatalf("执行次数 = %d, 期望 3", calls)
+	}
+
+	calls = 0
+	n := int32(0)
+	succeedSecond := func(ctx context.Context) bool {
+		atomic.AddInt32(&calls, 1)
+		return atomic.AddInt32(&n, 1) == 2
+	}
+	if !Retry(succeedSecond, 5, time.Millisecond)(context.Background()) {
+		t.Fatal("第2次成功时应返回 true")
+	}
+	if calls != 2 {
+		t.Fatalf("执行次数 = %d, 期望 2", calls)
+	}
+}
+
+func TestRetryBackoffCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var calls int32
+	start := time.Now()
+	if Retry(counted(false, &calls), 3, time.Minute)(ctx) {
+		t.Fatal("被中断时应返回 false")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("等待重试时未被中断, 耗时 %v", elapsed)
+	}
+	if calls != 1 {
+		t.Fatalf("执行次数 = %d, 期望 1", calls)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	var cancelled int32
+	start := time.Now()
+	if WithTimeout(blocking(&cancelled), 10*time.Millisecond)(context.Background()) {
+		t.Fatal("超时应返回 false")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("超时后操作未被中断, 耗时 %v", elapsed)
+	}
+	if cancelled != 1 {
+		t.Fatal("操作未收到取消")
+	}
+
+	var calls int32
+	if !WithTimeout(counted(true, &calls), time.Second)(context.Background()) {
+		t.Fatal("未超时的成功操作应返回 true")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	var primary, recovery int32
+	if !Fallback(counted(true, &primary), counted(false, &recovery))(context.Background()) {
+		t.Fatal("primary 成功时应返回 true")
+	}
+	if recovery != 0 {
+		t.Fatal("primary 成功时不应执行 recovery")
+	}
+
+	if !Fallback(counted(false, &primary), counted(true, &recovery))(context.Background()) {
+		t.Fatal("应以 recovery 的结果为准")
+	}
+	if recovery != 1 {
+		t.Fatalf("recovery 执行次数 = %d, 期望 1", recovery)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recovery = 0
+	if Fallback(counted(false, &primary), counted(true, &recovery))(ctx) {
+		t.Fatal("被中断时应返回 false")
+	}
+	if recovery != 0 {
+		t.Fatal("被中断时不应执行 recovery")
+	}
+}
+
+func TestSequence(t *testing.T) {
+	var a, b, c int32
+	if Sequence(counted(true, &a), counted(false, &b), counted(true, &c))(context.Background()) {
+		t.Fatal("有操作失败时应返回 false")
+	}
+	if a != 1 || b != 1 || c != 0 {
+		t.Fatalf("执行次数 = %d %d %d, 期望 1 1 0", a, b, c)
+	}
+
+	if !Sequence(counted(true, &a), counted(true, &c))(context.Background()) {
+		t.Fatal("全部成功时应返回 true")
+	}
+	if !Sequence()(context.Background()) {
+		t.Fatal("没有操作时应返回 true")
+	}
+}
+
+func TestRace(t *testing.T) {
+	var calls, cancelled int32
+	if !Race(blocking(&cancelled), counted(true, &calls), blocking(&cancelled))(context.Background()) {
+		t.Fatal("有操作成功时应返回 true")
+	}
+	// 返回前应等待被中断的操作结束
+	if cancelled != 2 {
+		t.Fatalf("被中断的操作数 = %d, 期望 2", cancelled)
+	}
+
+	if Race(counted(false, &calls), counted(false, &calls))(context.Background()) {
+		t.Fatal("全部失败时应返回 false")
+	}
+}
+
+func TestParallel(t *testing.T) {
+	var calls, cancelled int32
+	if Parallel(blocking(&cancelled), counted(false, &calls), blocking(&cancelled))(context.Background()) {
+		t.Fatal("有操作失败时应返回 false")
+	}
+	if cancelled != 2 {
+		t.Fatalf("被中断的操作数 = %d, 期望 2", cancelled)
+	}
+
+	calls = 0
+	if !Parallel(counted(true, &calls), counted(true, &calls), counted(true, &calls))(context.Background()) {
+		t.Fatal("全部成功时应返回 true")
+	}
+	if calls != 3 {
+		t.Fatalf("执行次数 = %d, 期望 3", calls)
+	}
+}
@@ -3,17 +3,17 @@ package script
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"star-map-tool/internal/detector"
 	"star-map-tool/internal/game"
 	"star-map-tool/internal/pkg/sleeper"
 	"star-map-tool/internal/pkg/utils"
 	"star-map-tool/internal/strategy"
-
-	"github.com/go-vgo/robotgo"
 )
 
 // 向前滑翔 w(down) + 2x space(tap) + q(tap)
@@ -42,90 +42,108 @@ type Script interface {
 	ChangeCameraAngleForY(x int, y int, angle int, baseline float32) Operation
 
 	Wait(duration int) Operation
+	// 每隔 interval 检测一次，直到 probe 识别到目标；超过 timeout 视为失败
+	WaitUntil(probe Probe, timeout time.Duration, interval time.Duration, getsctx func() *strategy.StrategyContext) Operation
+	// 每隔 interval 检测一次，直到 probe 识别不到目标；超过 timeout 视为失败
+	WaitWhile(probe Probe, timeout time.Duration, interval time.Duration, getsctx func() *strategy.StrategyContext) Operation
 	ExecTask(task func(*strategy.StrategyContext) (bool, error), getsctx func() *strategy.StrategyContext) Operation
 	Log(name string, mode string, message string) Operation
 }
 
-type Operation func() bool
+// 脚本中的单步操作，定义在 strategy 包中以便策略的公共部分执行
+type Operation = strategy.Operation
+
+// 识别函数，与 preset 中的 GetXxxArea 签名一致
+type Probe func(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool)
+
+type DefaultScript struct {
+	colorDetector detector.ColorDetector           // 供 Probe 使用
+	getsctx       func() *strategy.StrategyContext // 获取本轮的上下文，键鼠操作通过其中的 Game 进行
+}
 
-type DefaultScript struct{}
+func NewDefaultScript(colorDetector detector.ColorDetector, getsctx func() *strategy.StrategyContext) Script {
+	return &DefaultScript{colorDetector: colorDetector, getsctx: getsctx}
+}
 
-func NewDefaultScript() Script {
-	return &DefaultScript{}
+func (s *DefaultScript) input() game.InputSink {
+	return s.getsctx().Game.Input
 }
 
 func (s *DefaultScript) Move(keys []string, duration int) Operation {
-	return func() bool {
+	return func(ctx context.Context) bool {
+		input := s.input()
 		for i := range keys {
-			robotgo.KeyDown(keys[i])
+			input.KeyDown(keys[i])
 		}
 
-		sleeper.SleepBusyLoop(duration)
+		ok := sleeper.SleepBusyLoop(ctx, duration)
 
 		for i := len(keys) - 1; i >= 0; i-- {
-			robotgo.KeyUp(keys[i])
+			input.KeyUp(keys[i])
 		}
-		return true
+		return ok
 	}
 }
 
 func (s *DefaultScript) MoveAndOnce(keys []string, duration int,
 	task func(*strategy.StrategyContext) (bool, error), getsctx func() *strategy.StrategyContext) Operation {
-	return func() bool {
+	return func(ctx context.Context) bool {
+		input := s.input()
 		sctx := getsctx()
 
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
 		for i := range keys {
-			robotgo.KeyDown(keys[i])
+			input.KeyDown(keys[i])
 		}
 
 		// once 逻辑 (给子逻辑一次执行机会)
 		go func() {
-			once(ctx, task, sctx)
+			once(ctx, task, sctx.WithContext(ctx))
 		}()
-		sleeper.SleepBusyLoop(duration)
+		ok := sleeper.SleepBusyLoop(ctx, duration)
 		cancel()
 
 		for i := len(keys) - 1; i >= 0; i-- {
-			robotgo.KeyUp(keys[i])
+			input.KeyUp(keys[i])
 		}
-		return true
+		return ok
 	}
 }
 
 func (s *DefaultScript) MoveAndKeep(keys []string, duration int, interval int,
 	task func(*strategy.StrategyContext) (bool, error), getsctx func() *strategy.StrategyContext) Operation {
-	return func() bool {
+	return func(ctx context.Context) bool {
+		input := s.input()
 		sctx := getsctx()
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(duration)*time.Millisecond)
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(duration)*time.Millisecond)
 		defer cancel()
 
 		done := make(chan int, 1) // (1成功，2超时, 3失败)
 		defer close(done)
 
 		for i := range keys {
-			robotgo.KeyDown(keys[i])
+			input.KeyDown(keys[i])
 		}
 
 		// keep逻辑 (不停的执行子逻辑)
 		go func() {
-			keep(ctx, task, sctx, interval, done)
+			keep(ctx, task, sctx.WithContext(ctx), interval, done)
 		}()
 		result := <-done // 放行条件：超时 或者 子任务结束后主动关闭
 
 		for i := len(keys) - 1; i >= 0; i-- {
-			robotgo.KeyUp(keys[i])
+			input.KeyUp(keys[i])
 		}
 		return result == 1
 	}
 }
 
 func (s *DefaultScript) TapOnce(key string) Operation {
-	return func() bool {
-		robotgo.KeyTap(key)
+	return func(ctx context.Context) bool {
+		s.input().KeyTap(key)
 		time.Sleep(200 * time.Millisecond)
 
 		return true
@@ -133,12 +151,12 @@ func (s *DefaultScript) TapOnce(key string) Operation {
 }
 
 func (s *DefaultScript) Tap(key string, times int, interval int) Operation {
-	return func() bool {
+	return func(ctx context.Context) bool {
 		for i := range times {
-			robotgo.KeyTap(key)
+			s.input().KeyTap(key)
 
-			if i+1 != times && interval > 0 {
-				time.Sleep(time.Duration(interval) * time.Millisecond)
+			if i+1 != times && interval > 0 && !sleeper.Sleep(ctx, interval) {
+				return false
 			}
 		}
 		time.Sleep(200 * time.Millisecond)
@@ -147,36 +165,36 @@ func (s *DefaultScript) Tap(key string, times int, interval int) Operation {
 }
 
 func (s *DefaultScript) Scroll(x int, direction string) Operation {
-	return func() bool {
-		robotgo.ScrollDir(x, direction)
+	return func(ctx context.Context) bool {
+		s.input().Scroll(x, direction)
 		return true
 	}
 }
 
 func (s *DefaultScript) MouseClick() Operation {
-	return func() bool {
-		robotgo.Click()
+	return func(ctx context.Context) bool {
+		s.input().Click()
 		return true
 	}
 }
 
 func (s *DefaultScript) MouseMove(x, Y int) Operation {
-	return func() bool {
-		robotgo.Move(x, Y)
+	return func(ctx context.Context) bool {
+		s.input().Move(x, Y)
 		return true
 	}
 }
 
 func (s *DefaultScript) MouseMoveClick(x, y int) Operation {
-	return func() bool {
-		robotgo.MoveClick(x, y)
+	return func(ctx context.Context) bool {
+		s.input().MoveClick(x, y)
 		return true
 	}
 }
 
 func (s *DefaultScript) MouseDragSmooth(x int, y int, speed float32) Operation {
-	return func() bool {
-		robotgo.DragSmooth(x, y, speed)
+	return func(ctx context.Context) bool {
+		s.input().DragSmooth(x, y, speed)
 		return true
 	}
 }
@@ -184,33 +202,66 @@ func (s *DefaultScript) MouseDragSmooth(x int, y int, speed float32) Operation {
 func (s *DefaultScript) ChangeCameraAngleForX(x int, y int, angle int, baseline float32) Operation {
 	// 这里求出来的并不是准确的，需要根据场景做调整(当镜头与角色距离不同时，得到的结果是不同的)
 	// 当默认镜头距离时(滚轮5个单位)，每3.24像素近似于1度；当最小镜头距离时，每3.24像素近似于1度
-	return func() bool {
-		ChangeCameraAngleForX(x, y, angle, baseline)
+	return func(ctx context.Context) bool {
+		ChangeCameraAngleForX(s.input(), x, y, angle, baseline)
 		return true
 	}
 }
 
 func (s *DefaultScript) ChangeCameraAngleForY(x int, y int, angle int, baseline float32) Operation {
 	// 7.8 随便选的
-	return func() bool {
-		ChangeCameraAngleForY(x, y, angle, baseline)
+	return func(ctx context.Context) bool {
+		ChangeCameraAngleForY(s.input(), x, y, angle, baseline)
 		return true
 	}
 }
 
 func (s *DefaultScript) Wait(duration int) Operation {
-	return func() bool {
-		sleeper.SleepBusyLoop(duration)
-		return true
+	return func(ctx context.Context) bool {
+		return sleeper.SleepBusyLoop(ctx, duration)
+	}
+}
+
+func (s *DefaultScript) WaitUntil(probe Probe, timeout time.Duration, interval time.Duration, getsctx func() *strategy.StrategyContext) Operation {
+	return s.wait(probe, true, timeout, interval, getsctx)
+}
+
+func (s *DefaultScript) WaitWhile(probe Probe, timeout time.Duration, interval time.Duration, getsctx func() *strategy.StrategyContext) Operation {
+	return s.wait(probe, false, timeout, interval, getsctx)
+}
+
+func (s *DefaultScript) wait(probe Probe, expect bool, timeout time.Duration, interval time.Duration, getsctx func() *strategy.StrategyContext) Operation {
+	return func(ctx context.Context) bool {
+		sctx := getsctx()
+		check := func() (bool, error) {
+			_, ok := probe(*sctx.Game, s.colorDetector)
+			return ok == expect, nil
+		}
+		if ok, _ := check(); ok {
+			return true
+		}
+
+		ok, err := utils.NewTicker(ctx, timeout, interval, check, false)
+		if !ok && ctx.Err() == nil {
+			if expect {
+				err = fmt.Errorf("%v内未识别到目标: %w", timeout, err)
+			} else {
+				err = fmt.Errorf("%v内目标未消失: %w", timeout, err)
+			}
+			log.Printf("[脚本] %v\n", err)
+			sctx.SetError(err)
+		}
+		return ok
 	}
 }
 
 func (s *DefaultScript) ExecTask(task func(*strategy.StrategyContext) (bool, error), getsctx func() *strategy.StrategyContext) Operation {
-	return func() bool {
+	return func(ctx context.Context) bool {
 		sctx := getsctx()
 
-		ok, err := task(sctx)
+		ok, err := task(sctx.WithContext(ctx)) // 任务中应使用 sctx.Ctx，才能被组合器中断
 		if err != nil {
+			sctx.SetError(err)
 			return false
 		}
 		return ok
@@ -218,7 +269,7 @@ func (s *DefaultScript) ExecTask(task func(*strategy.StrategyContext) (bool, err
 }
 
 func (s *DefaultScript) Log(name string, mode string, message string) Operation {
-	return func() bool {
+	return func(ctx context.Context) bool {
 		log.Printf("[%s-%s] %s\n", name, mode, message)
 		return true
 	}
@@ -262,42 +313,42 @@ func keep(ctx context.Context, task func(*strategy.StrategyContext) (bool, error
 	}
 }
 
-func ChangeCameraAngleForX(x int, y int, angle int, baseline float32) {
+func ChangeCameraAngleForX(input game.InputSink, x int, y int, angle int, baseline float32) {
 	// 这里求出来的并不是准确的，需要根据场景做调整(当镜头与角色距离不同时，得到的结果是不同的)
 	// 当默认镜头距离时(滚轮5个单位)，每3.24像素近似于1度；当最小镜头距离时，每3.24像素近似于1度
-	robotgo.KeyDown("alt")
-	robotgo.Toggle("left")
+	input.KeyDown("alt")
+	input.MouseDown()
 
 	time.Sleep(time.Duration(100) * time.Millisecond) // 等待上方事件起作用 （键盘和鼠标衔接的地方仍要等待）
 	offsetX := int(float32(angle) * baseline)
-	robotgo.Move(x+offsetX, y)
+	input.Move(x+offsetX, y)
 
-	robotgo.Toggle("left", "up")
-	robotgo.KeyUp("alt")
+	input.MouseUp()
+	input.KeyUp("alt")
 	time.Sleep(time.Duration(100) * time.Millisecond) // 等待上方事件起作用 （键盘和鼠标衔接的地方仍要等待）
 }
 
-func ChangeCameraAngleForY(x int, y int, angle int, baseline float32) {
-	robotgo.KeyDown("alt")
-	robotgo.Toggle("left")
+func ChangeCameraAngleForY(input game.InputSink, x int, y int, angle int, baseline float32) {
+	input.KeyDown("alt")
+	input.MouseDown()
 
 	time.Sleep(time.Duration(50) * time.Millisecond) // 等待上方事件起作用 （键盘和鼠标衔接的地方仍要等待）
 	offsetY := int(float32(angle) * baseline)
-	robotgo.Move(x, y+offsetY)
+	input.Move(x, y+offsetY)
 
-	robotgo.Toggle("left", "up")
-	robotgo.KeyUp("alt")
+	input.MouseUp()
+	input.KeyUp("alt")
 }
 
-func Scroll(x int, direction string) {
-	robotgo.ScrollDir(x, direction)
+func Scroll(input game.InputSink, x int, direction string) {
+	input.Scroll(x, direction)
 }
 
-func MoveCircle(duration int, interval int, signal *int32) bool {
+func MoveCircle(ctx context.Context, input game.InputSink, duration int, interval int, signal *int32) bool {
 	var state int32 = 1
 
 	start := time.Now()
-	ok, _ := utils.NewTicker(time.Duration(duration)*time.Millisecond, time.Duration(interval)*time.Millisecond, func() (bool, error) {
+	ok, _ := utils.NewTicker(ctx, time.Duration(duration)*time.Millisecond, time.Duration(interval)*time.Millisecond, func() (bool, error) {
 		if val := atomic.LoadInt32(signal); val == 1 {
 			return true, nil // 收到通知就停下
 		}
@@ -305,29 +356,29 @@ func MoveCircle(duration int, interval int, signal *int32) bool {
 		s := atomic.LoadInt32(&state)
 		switch s {
 		case 1:
-			robotgo.KeyUp("a")
-			robotgo.KeyDown("s") // s
+			input.KeyUp("a")
+			input.KeyDown("s") // s
 			atomic.StoreInt32(&state, 2)
 		case 2:
-			robotgo.KeyDown("d") // s + d
+			input.KeyDown("d") // s + d
 			atomic.StoreInt32(&state, 3)
 		case 3:
-			robotgo.KeyUp("s") // d
+			input.KeyUp("s") // d
 			atomic.StoreInt32(&state, 4)
 		case 4:
-			robotgo.KeyDown("w") // d + w
+			input.KeyDown("w") // d + w
 			atomic.StoreInt32(&state, 5)
 		case 5:
-			robotgo.KeyUp("d") // w
+			input.KeyUp("d") // w
 			atomic.StoreInt32(&state, 6)
 		case 6:
-			robotgo.KeyDown("a") // w + a
+			input.KeyDown("a") // w + a
 			atomic.StoreInt32(&state, 7)
 		case 7:
-			robotgo.KeyUp("w") // a
+			input.KeyUp("w") // a
 			atomic.StoreInt32(&state, 8)
 		case 8:
-			robotgo.KeyDown("s") // a + s
+			input.KeyDown("s") // a + s
 			atomic.StoreInt32(&state, 1)
 		}
 
@@ -339,7 +390,7 @@ func MoveCircle(duration int, interval int, signal *int32) bool {
 	return ok
 }
 
-func MoveSide(direction int, interval int, speed int, stop chan int) bool {
+func MoveSide(ctx context.Context, input game.InputSink, direction int, interval int, speed int, stop chan int) bool {
 	var moveKeyList = []string{"w", "a", "s", "d", "shift", "ctrl"}
 	var speedList = []string{"ctrl", "", "shift"}
 	times := 0
@@ -354,7 +405,7 @@ func MoveSide(direction int, interval int, speed int, stop chan int) bool {
 		stepList = []string{"d,w", "w", "w"}
 	}
 
-	x, y := robotgo.Location()
+	x, y := input.Location()
 	getCurrStepIndex := func(t int) int {
 		if t >= 3 {
 			return t % 3
@@ -380,20 +431,20 @@ func MoveSide(direction int, interval int, speed int, stop chan int) bool {
 	move := func(stepList []string, times int) {
 		speedKey := speedList[speed+1]
 		if len(stepList) > 0 {
-			robotgo.KeyUp(speedKey)
+			input.KeyUp(speedKey)
 		}
 		prevKeys := stepList[getPrevStepIndex(times)]
 		list := strings.SplitSeq(prevKeys, ",")
 		for k := range list {
-			robotgo.KeyUp(k)
+			input.KeyUp(k)
 		}
 		currKeys := stepList[getCurrStepIndex(times)]
 		list = strings.SplitSeq(currKeys, ",")
 		for k := range list {
-			robotgo.KeyDown(k)
+			input.KeyDown(k)
 		}
 		if len(stepList) > 0 {
-			robotgo.KeyDown(speedKey)
+			input.KeyDown(speedKey)
 		}
 	}
 	move(stepList, times)
@@ -405,12 +456,17 @@ func MoveSide(direction int, interval int, speed int, stop chan int) bool {
 		select {
 		case <-stop:
 			for _, k := range moveKeyList {
-				robotgo.KeyUp(k)
+				input.KeyUp(k)
 			}
 			return true
+		case <-ctx.Done():
+			for _, k := range moveKeyList {
+				input.KeyUp(k)
+			}
+			return false
 		case <-ticker.C:
 			if times > 0 {
-				ChangeCameraAngleForX(x, y, -direction*24, 3.24)
+				ChangeCameraAngleForX(input, x, y, -direction*24, 3.24)
 			}
 			move(stepList, times)
 			times++
@@ -419,14 +475,15 @@ func MoveSide(direction int, interval int, speed int, stop chan int) bool {
 }
 
 func HandleAbnormalTeam(game *game.Game) {
-	robotgo.KeyTap("i")
+	input := game.Input
+	input.KeyTap("i")
 	time.Sleep(time.Duration(3) * time.Second)
 
 	// 点击退出队伍
-	robotgo.MoveClick(1167, 730)
+	input.MoveClick(1167, 730)
 
 	// 确认退出队伍
-	robotgo.MoveClick(795, 580)
+	input.MoveClick(795, 580)
 
-	robotgo.MoveClick(1233, 66)
+	input.MoveClick(1233, 66)
 }
@@ -1,28 +1,47 @@
 package sleeper
 
 import (
+	"context"
 	"time"
 )
 
 // 目前未全面实现基于图形的目的检测，只能通过这种方式暂时使
+// 等待期间 ctx 被取消时立即返回 false
 
-func SleepBusyLoop(duration int) {
+func SleepBusyLoop(ctx context.Context, duration int) bool {
 	start := time.Now()
 	val := time.Duration(duration * int(time.Millisecond))
 
 	// 让时间的大头使用sleep执行
 	if val > 500*time.Millisecond {
-		time.Sleep(val - 500*time.Millisecond)
+		if !Sleep(ctx, int((val-500*time.Millisecond)/time.Millisecond)) {
+			return false
+		}
 	}
 	if time.Since(start) > val {
-		return
+		return true
 	}
 
 	// 让500毫秒内的逻辑使用忙循环
+	done := ctx.Done()
 	for time.Since(start) < val {
+		select {
+		case <-done:
+			return false
+		default:
+		}
 	}
+	return true
 }
 
-func Sleep(duration int) {
-	time.Sleep(time.Duration(duration) * time.Millisecond)
+func Sleep(ctx context.Context, duration int) bool {
+	timer := time.NewTimer(time.Duration(duration) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
@@ -1,11 +1,13 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"time"
 )
 
-func NewTicker(duration time.Duration, interval time.Duration, f func() (bool, error), immediate bool) (bool, error) {
+// 每隔 interval 执行一次 f，直到 f 返回 true、返回错误、超过 duration 或 ctx 被取消
+func NewTicker(ctx context.Context, duration time.Duration, interval time.Duration, f func() (bool, error), immediate bool) (bool, error) {
 	startTime := time.Now()
 	if immediate {
 		f() // 立即执行一次
@@ -19,7 +21,11 @@ func NewTicker(duration time.Duration, interval time.Duration, f func() (bool, e
 			return false, errors.New("执行超时")
 		}
 
-		<-ticker.C
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
 		result, err := f()
 
 		if err != nil {
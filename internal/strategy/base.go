@@ -0,0 +1,250 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"star-map-tool/internal/detector"
+	"star-map-tool/internal/game"
+	"star-map-tool/internal/pkg/sleeper"
+	"star-map-tool/internal/strategy/preset"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 脚本中的单步操作，返回false代表执行失败，ctx 被取消时应尽快返回
+type Operation func(ctx context.Context) bool
+
+// 副本中的一个环节（前往地下城、开启地下城、各个关卡等）
+type Scene struct {
+	Name       string
+	Operations []Operation
+}
+
+// 各副本策略的公共部分：启用状态、死亡检测、操作执行与退出副本
+// 具体策略嵌入后只需提供名称、模式以及各个环节
+type BaseStrategy struct {
+	name   string
+	mode   string
+	enable int32 // 1: 执行中、0: 已停止
+
+	lock    sync.Mutex
+	reason  Reason // 停止的原因，只记录第一次
+	message string
+	scene   string // 当前所在的环节
+	cancel  context.CancelFunc
+
+	Context       *StrategyContext // 每次执行时都是新的
+	ColorDetector detector.ColorDetector
+}
+
+func NewBaseStrategy(name string, mode string) BaseStrategy {
+	return BaseStrategy{name: name, mode: mode, enable: 1}
+}
+
+func (b *BaseStrategy) GetName() string {
+	return b.name
+}
+
+func (b *BaseStrategy) GetMode() string {
+	return b.mode
+}
+
+func (b *BaseStrategy) IsEnable() bool {
+	return atomic.LoadInt32(&b.enable) == 1
+}
+
+func (b *BaseStrategy) Enable() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.reason, b.message, b.scene = STRATEGY_REASON_SUCCESS, "", ""
+	atomic.StoreInt32(&b.enable, 1)
+}
+
+// 停止执行并记录原因，已停止时不再覆盖之前的原因
+func (b *BaseStrategy) Disable(reason Reason, message string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if atomic.CompareAndSwapInt32(&b.enable, 1, 0) {
+		b.reason, b.message = reason, message
+		if b.cancel != nil {
+			b.cancel() // 中断正在执行的等待
+		}
+	}
+}
+
+func (b *BaseStrategy) stopReason() Reason {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.reason
+}
+
+func (b *BaseStrategy) StartDeathCheck(ctx *StrategyContext) {
+	atomic.StoreInt32(&ctx.DeathCheckFlag, 1) // 开启死亡检测
+}
+
+func (b *BaseStrategy) StopDeathCheck(ctx *StrategyContext) {
+	atomic.StoreInt32(&ctx.DeathCheckFlag, 0) // 关闭死亡检测
+}
+
+// 本轮游戏的键鼠操作
+func (b *BaseStrategy) Input() game.InputSink {
+	return b.Context.Game.Input
+}
+
+func (b *BaseStrategy) Init() {
+	b.ColorDetector = detector.NewColorDetector()
+}
+
+func (b *BaseStrategy) Abort(sign string) {
+	switch sign {
+	case STRATEGY_EVENT_TIMEOUT:
+		b.Disable(STRATEGY_REASON_TIMEOUT, "已达到单轮限时")
+	case STRATEGY_EVENT_TIME_LEFT:
+		b.Disable(STRATEGY_REASON_TIMEOUT, "副本剩余时间不足")
+	default:
+		b.Disable(STRATEGY_REASON_ABORT, "已被中断")
+	}
+}
+
+// 本轮的 ctx 已被取消（超时或中断）时停止执行，执行器的通知可能晚于 ctx 的取消
+func (b *BaseStrategy) interrupted() bool {
+	err := b.Context.Ctx.Err()
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		b.Abort(STRATEGY_EVENT_TIMEOUT)
+	default:
+		b.Abort(STRATEGY_EVENT_OTHER)
+	}
+	return true
+}
+
+// 绑定本轮的上下文并开启死亡检测，随后依次执行 scenes 返回的各个环节
+// 环节在绑定上下文之后才生成，便于其中的操作获取本轮的上下文
+func (b *BaseStrategy) Launch(sctx *StrategyContext, scenes func() []Scene) Outcome {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(sctx.Ctx)
+	defer cancel()
+
+	b.Context = sctx
+	b.Context.Ctx = ctx
+	b.Context.Attrs["START_TIME"] = start
+	b.Enable()
+	b.lock.Lock()
+	b.cancel = cancel
+	b.lock.Unlock()
+	go b.runDeathCheck()
+
+	b.Run(scenes())
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return Outcome{
+		Reason:  b.reason,
+		Scene:   b.scene,
+		Step:    int(atomic.LoadInt32(&sctx.Step)),
+		Message: b.message,
+		Elapsed: time.Since(start),
+	}
+}
+
+// 依次执行各个环节，失败或被中断时退出副本
+func (b *BaseStrategy) Run(scenes []Scene) bool {
+	for _, scene := range scenes {
+		b.lock.Lock()
+		b.scene = scene.Name
+		b.lock.Unlock()
+
+		if !b.runOperations(scene.Operations) {
+			return false
+		}
+	}
+	b.Disable(STRATEGY_REASON_SUCCESS, "") // 让子线程有停止的机会
+	return true
+}
+
+// 依次执行操作，失败或被中断时退出副本
+func (b *BaseStrategy) runOperations(list []Operation) bool {
+	for i, op := range list {
+		if !b.IsEnable() || b.interrupted() {
+			b.ExitDungeon()
+			return false
+		}
+		atomic.StoreInt32(&b.Context.Step, int32(i+1))
+		b.Context.SetError(nil)
+		ok := op(b.Context.Ctx)
+		if !ok {
+			if !b.interrupted() {
+				message := "操作执行失败"
+				if err := b.Context.Error(); err != nil {
+					message = err.Error()
+				}
+				b.Disable(STRATEGY_REASON_FAIL, message)
+			}
+			b.ExitDungeon()
+			return false
+		}
+	}
+	return true
+}
+
+// 玩家血量不高于此百分比时视为血量较低
+const LowHealthPercent = 30
+
+func (b *BaseStrategy) runDeathCheck() {
+	// 死亡处理：一般只检测途中，死亡后直接退出（如果不退出需要更复杂的操作去识别、修正行为）
+	running, low := false, false
+	for {
+		flag := atomic.LoadInt32(&b.Context.DeathCheckFlag)
+		if running && flag == 0 {
+			// 逻辑内关闭死亡检测 - 通常到达BOSS战才会关闭
+			return
+		} else if !b.IsEnable() {
+			// 有其他逻辑中断策略执行，停止死亡检测
+			return
+		} else if flag == 0 {
+			sleeper.Sleep(b.Context.Ctx, 200)
+			continue
+		}
+		if !running {
+			running = true
+		}
+
+		health, ok := preset.GetPlayerHealthPercent(*b.Context.Game)
+		if ok && health.Percent <= LowHealthPercent && !low {
+			log.Printf("[%s-%s] 玩家血量较低: %v\n", b.GetName(), b.GetMode(), health)
+		}
+		low = ok && health.Percent <= LowHealthPercent
+		flag = atomic.LoadInt32(&b.Context.DeathCheckFlag)
+		if !ok && flag == 1 { // 已死亡
+			log.Printf("[%s-%s] 未检测到玩家血条,认定为已死亡(即将执行P出逻辑)\n", b.GetName(), b.GetMode())
+			running = false
+			b.Disable(STRATEGY_REASON_DEATH, "未检测到玩家血条") // 交给主线程去退出对局
+			return
+		}
+		sleeper.Sleep(b.Context.Ctx, 200)
+	}
+}
+
+// 执行失败或死亡时退出副本
+func (b *BaseStrategy) ExitDungeon() {
+	reason := b.stopReason()
+	if reason == STRATEGY_REASON_FAIL || reason == STRATEGY_REASON_DEATH {
+		// 此时本轮的 ctx 已被取消，不能再使用 sleeper
+		input := b.Input()
+		input.Click() // 有可能小月卡弹框
+		time.Sleep(200 * time.Millisecond)
+
+		// 直接p，死亡状态按p是无效的，如果能退就退了
+		input.KeyTap("p")
+		input.MoveClick(794, 579)
+
+		// p不出去就点死亡时出现的退出按钮
+		input.MoveClick(1179, 67)
+		input.MoveClick(794, 579)
+		log.Printf("[%s-%s] 已执行副本退出逻辑\n", b.GetName(), b.GetMode())
+	}
+}
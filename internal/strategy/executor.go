@@ -3,12 +3,20 @@ package strategy
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"star-map-tool/internal/game"
 	"star-map-tool/internal/listener"
+	"star-map-tool/internal/strategy/preset"
+	"strings"
 	"time"
 )
 
+const (
+	DungeonTimeInterval = time.Second // 检查副本时间的间隔
+	DungeonTimeReads    = 3           // 连续多少次识别结果与经过的时间相符才认定为倒计时，避免单次误识别放弃本轮
+)
+
 type Executor struct {
 	selector *Selector
 	result   ExecutionResult
@@ -20,12 +28,16 @@ type ExecutionConfig struct {
 	Timeout  time.Duration      // 每轮执行的超时时间，超时后放弃此轮执行，并开始下一轮
 	Interval time.Duration      // 每轮执行的间隔
 	Listener *listener.Listener // 执行状态控制器
+
+	MinTimeLeft time.Duration // 游戏内的副本倒计时低于此值时放弃此轮，0代表不检查
 }
 
 type ExecutionResult struct {
-	times   int
-	success int
-	fail    int
+	times    int
+	success  int
+	fail     int
+	reasons  map[Reason]int // 各结束原因的轮数
+	outcomes []Outcome      // 每一轮的执行结果
 }
 
 func NewExecutor(selector *Selector) *Executor {
@@ -39,7 +51,10 @@ func (e *Executor) Execute(config *ExecutionConfig, strategy Strategy, data inte
 		log.Println("[执行器] 未找到执行状态控制器,已退出程序!")
 		return
 	}
-	strategy.Init()
+	if err := strategy.Init(); err != nil {
+		log.Printf("[执行器] 策略初始化失败, 已停止执行: %v\n", err)
+		return
+	}
 
 	timeout := time.Duration(config.Timeout)
 	for range config.Times {
@@ -55,31 +70,47 @@ func (e *Executor) Execute(config *ExecutionConfig, strategy Strategy, data inte
 		}
 		defer cancel()
 
-		sctx := NewStrategyContext(config.Game)
-		endReason := e.execute0(ctx, strategy, sctx, data)
-		if endReason == STRATEGY_REASON_SUCCESS {
-			e.record(true)
-		} else {
-			e.record(false)
+		recorder := config.Game.Recorder
+		if recorder != nil {
+			name := fmt.Sprintf("%s-%s-%s-%03d", start.Format("20060102-150405"), strategy.GetName(), strategy.GetMode(), e.result.times+1)
+			if err := recorder.Begin(name, config.Game.Rect); err != nil {
+				log.Printf("[执行器] 开始录制失败: %v\n", err)
+			}
 		}
 
-		elapsed := time.Since(start)
-		log.Printf("[执行器] 本轮耗时%d秒", int(elapsed.Seconds()))
-		log.Printf("[执行器] 已执行%d轮 成功%d轮 失败%d轮\n", e.result.times, e.result.success, e.result.fail)
+		sctx := NewStrategyContext(ctx, config.Game)
+		outcome := e.execute0(ctx, strategy, sctx, data, config.MinTimeLeft)
+		outcome.Elapsed = time.Since(start)
+		e.record(outcome)
+		if recorder != nil {
+			if err := recorder.End(outcome.String()); err != nil {
+				log.Printf("[执行器] 结束录制失败: %v\n", err)
+			}
+		}
+
+		log.Printf("[执行器] 本轮结果: %s", outcome)
+		log.Printf("[执行器] 本轮耗时%d秒", int(outcome.Elapsed.Seconds()))
+		log.Printf("[执行器] 已执行%d轮 成功%d轮 失败%d轮%s\n", e.result.times, e.result.success, e.result.fail, e.failSummary())
 		time.Sleep(6 * time.Second)
 		time.Sleep(config.Interval)
 	}
 }
 
-func (e *Executor) execute0(ctx context.Context, strategy Strategy, sctx *StrategyContext, data any) int32 {
-	done := make(chan bool, 1)
+func (e *Executor) execute0(ctx context.Context, strategy Strategy, sctx *StrategyContext, data any, minTimeLeft time.Duration) Outcome {
+	done := make(chan Outcome, 1)
 	defer close(done)
 	start := time.Now()
 
+	if minTimeLeft > 0 {
+		watchCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		go e.watchDungeonTime(watchCtx, strategy, sctx, minTimeLeft)
+	}
+
 	go func() {
-		ok := strategy.Execute(sctx, data)
-		game.ReleaseAllKey()
-		done <- ok
+		outcome := strategy.Execute(sctx, data)
+		sctx.Game.ReleaseAllKey()
+		done <- outcome
 	}()
 
 	select {
@@ -88,30 +119,111 @@ func (e *Executor) execute0(ctx context.Context, strategy Strategy, sctx *Strate
 		log.Printf("[执行器] 检测到本轮已执行%.2f分钟, 已达到超时条件, 即将进行P本并开始下一轮 \n", elapsed.Minutes())
 		err := ctx.Err()
 
-		var reason int32
+		var reason Reason
 		if errors.Is(err, context.DeadlineExceeded) {
 			strategy.Abort(STRATEGY_EVENT_TIMEOUT) // 异步通知正在执行的线程应该停止了(停止是需要时间的)
 			reason = STRATEGY_REASON_TIMEOUT
 		} else {
 			strategy.Abort(STRATEGY_EVENT_OTHER)
-			reason = STRATEGY_REASON_OTHER
+			reason = STRATEGY_REASON_ABORT
 		}
-		<-done // 等待子线程返回执行成功或者停止成功的信号
-		return reason
-	case result := <-done:
-		if result {
-			return STRATEGY_REASON_SUCCESS
-		} else {
-			return STRATEGY_REASON_FAIL
+		outcome := <-done // 等待子线程返回执行成功或者停止成功的信号
+		if outcome.Success() {
+			outcome.Reason = reason // 停止期间恰好执行完毕，仍按超时或终止计算
+		}
+		return outcome
+	case outcome := <-done:
+		return outcome
+	}
+}
+
+// 按游戏内的副本时间检查剩余时间，倒计时低于 minTimeLeft 时放弃本轮
+// 正计时的副本、识别结果不稳定时不做检查
+func (e *Executor) watchDungeonTime(ctx context.Context, strategy Strategy, sctx *StrategyContext, minTimeLeft time.Duration) {
+	ticker := time.NewTicker(DungeonTimeInterval)
+	defer ticker.Stop()
+
+	var timer countdown
+	var deadline <-chan time.Time // 按倒计时推算出的结束时间，识别不到时间时也能按时结束
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			log.Printf("[执行器] 副本剩余时间已不足%v, 即将进行P本并开始下一轮\n", minTimeLeft)
+			strategy.Abort(STRATEGY_EVENT_TIME_LEFT)
+			return
+		case <-ticker.C:
+		}
+
+		reading, ok := preset.GetDungeonTime(*sctx.Game)
+		if !ok || !timer.update(reading.Duration, time.Now()) {
+			continue
+		}
+
+		if reading.Duration <= minTimeLeft {
+			log.Printf("[执行器] 副本剩余时间%s, 已不足%v, 即将进行P本并开始下一轮\n", reading.Text, minTimeLeft)
+			strategy.Abort(STRATEGY_EVENT_TIME_LEFT)
+			return
+		}
+
+		// 游戏的时限早于单轮限时时，以游戏时间为准
+		if deadline == nil {
+			end := time.Now().Add(reading.Duration - minTimeLeft)
+			if d, ok := ctx.Deadline(); !ok || end.Before(d) {
+				log.Printf("[执行器] 副本剩余时间%s, 本轮将在剩余%v时结束\n", reading.Text, minTimeLeft)
+				t := time.NewTimer(time.Until(end))
+				defer t.Stop()
+				deadline = t.C
+			}
 		}
 	}
 }
 
-func (e *Executor) record(success bool) {
-	if success {
+// 副本倒计时的识别记录
+type countdown struct {
+	last   time.Duration // 上一次识别的剩余时间
+	at     time.Time     // 上一次识别的时间
+	streak int           // 连续与经过的时间相符的减少次数
+}
+
+// 记录一次识别结果，剩余时间的减少量与经过的时间相差不超过1秒才算相符(时间只精确到秒)
+// 连续 DungeonTimeReads 次相符时返回 true，任何一次不相符都会重新计数
+func (c *countdown) update(value time.Duration, now time.Time) bool {
+	if !c.at.IsZero() && value < c.last && (c.last-value-now.Sub(c.at)).Abs() <= time.Second {
+		c.streak++
+	} else {
+		c.streak = 0
+	}
+	c.last, c.at = value, now
+	return c.streak >= DungeonTimeReads
+}
+
+func (e *Executor) record(outcome Outcome) {
+	if outcome.Success() {
 		e.result.success = e.result.success + 1
 	} else {
 		e.result.fail = e.result.fail + 1
 	}
 	e.result.times = e.result.times + 1
+
+	if e.result.reasons == nil {
+		e.result.reasons = make(map[Reason]int)
+	}
+	e.result.reasons[outcome.Reason]++
+	e.result.outcomes = append(e.result.outcomes, outcome)
+}
+
+// 失败原因统计，例如: (死亡2轮 超时1轮)
+func (e *Executor) failSummary() string {
+	var list []string
+	for reason := STRATEGY_REASON_FAIL; reason <= STRATEGY_REASON_DEATH; reason++ {
+		if count := e.result.reasons[reason]; count > 0 {
+			list = append(list, fmt.Sprintf("%s%d轮", reason, count))
+		}
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(list, " ") + ")"
 }
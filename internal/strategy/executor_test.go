@@ -0,0 +1,68 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountdown(t *testing.T) {
+	type read struct {
+		at    time.Duration // 距开始的时间
+		value time.Duration // 识别出的剩余时间
+		want  bool
+	}
+	m := func(minutes, seconds int) time.Duration {
+		return time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	}
+	cases := []struct {
+		name  string
+		reads []read
+	}{
+		{"倒计时", []read{
+			{0, m(5, 0), false},
+			{time.Second, m(4, 59), false},
+			{2 * time.Second, m(4, 58), false},
+			{3 * time.Second, m(4, 57), true},
+			{4 * time.Second, m(4, 56), true},
+		}},
+		{"漏掉的识别", []read{
+			{0, m(5, 0), false},
+			{time.Second, m(4, 59), false},
+			{3 * time.Second, m(4, 57), false},
+			{4 * time.Second, m(4, 56), true},
+		}},
+		{"单次误识别", []read{
+			{0, m(5, 0), false},
+			{time.Second, m(4, 59), false},
+			{2 * time.Second, m(4, 58), false},
+			{3 * time.Second, m(0, 18), false}, // 与经过的时间不符
+			{4 * time.Second, m(4, 56), false},
+			{5 * time.Second, m(4, 55), false},
+			{6 * time.Second, m(4, 54), false},
+			{7 * time.Second, m(4, 53), true},
+		}},
+		{"正计时", []read{
+			{0, m(1, 0), false},
+			{time.Second, m(1, 1), false},
+			{2 * time.Second, m(1, 2), false},
+			{3 * time.Second, m(1, 3), false},
+			{4 * time.Second, m(1, 4), false},
+		}},
+		{"时间不变", []read{
+			{0, m(5, 0), false},
+			{time.Second, m(5, 0), false},
+			{2 * time.Second, m(5, 0), false},
+			{3 * time.Second, m(5, 0), false},
+		}},
+	}
+
+	start := time.Now()
+	for _, c := range cases {
+		var timer countdown
+		for i, r := range c.reads {
+			if got := timer.update(r.value, start.Add(r.at)); got != r.want {
+				t.Errorf("%s: 第%d次识别 %v = %v, 期望 %v", c.name, i+1, r.value, got, r.want)
+			}
+		}
+	}
+}
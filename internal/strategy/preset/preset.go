@@ -2,167 +2,186 @@ package preset
 
 import (
 	"image"
-	"image/color"
+	"log"
+	"star-map-tool/assets"
 	"star-map-tool/internal/detector"
 	"star-map-tool/internal/game"
-	"star-map-tool/internal/pkg/utils"
+	"sync"
 )
 
 var (
 	// 小地图 - 副本入口才有的紫色标记
 	MainArea  = []int{62, 74, 133, 147}
-	MainColor = []color.RGBA{{115, 25, 214, 0}, {150, 255, 255, 0}}
+	MainColor = detector.MustHSVRange(detector.HSV{H: 115, S: 25, V: 214}, detector.HSV{H: 150, S: 255, V: 255})
 
 	// 右下角 - 匹配进入/进入副本按钮
 	DungeonQueueArea  = []int{985, 710, 1250, 755}
-	DungeonQueueColor = []color.RGBA{{0, 0, 135, 0}, {225, 225, 255, 0}}
+	DungeonQueueColor = detector.MustHSVRange(detector.HSV{H: 0, S: 0, V: 135}, detector.HSV{H: 179, S: 225, V: 255})
 
 	// 左上角 - 骷髅标
 	DungeonReadyArea  = []int{140, 50, 164, 68}
-	DungeonReadyColor = []color.RGBA{{0, 0, 190, 0}, {225, 225, 255, 0}}
+	DungeonReadyColor = detector.MustHSVRange(detector.HSV{H: 0, S: 0, V: 190}, detector.HSV{H: 179, S: 225, V: 255})
 
 	// 右上角 - 副本时间
 	DungeonRunningArea  = []int{1194, 52, 1250, 67}
-	DungeonRunningColor = []color.RGBA{{0, 0, 155, 0}, {225, 225, 255, 0}}
+	DungeonRunningColor = detector.MustHSVRange(detector.HSV{H: 0, S: 0, V: 155}, detector.HSV{H: 179, S: 225, V: 255})
 
 	// 中下 - 角色血条
 	PlayerHealthArea  = []int{471, 751, 808, 773}
-	PlayerHealthColor = []color.RGBA{{0, 190, 255, 0}, {80, 225, 255, 0}}
+	PlayerHealthColor = detector.MustHSVRange(detector.HSV{H: 0, S: 190, V: 255}, detector.HSV{H: 80, S: 225, V: 255})
 
 	// 中上 - 红色血条
 	BossHealth      = []int{494, 51, 799, 72}
-	BossHealthColor = []color.RGBA{{0, 236, 244, 0}, {25, 255, 255, 0}}
+	BossHealthColor = detector.MustHSVRange(detector.HSV{H: 0, S: 236, V: 244}, detector.HSV{H: 25, S: 255, V: 255})
 
 	// 中上 - 灰色血条
 	BossGrayHealthArea  = []int{494, 51, 799, 72}
-	BossGrayHealthColor = []color.RGBA{{0, 0, 159, 0}, {0, 0, 174, 0}}
+	BossGrayHealthColor = detector.MustHSVRange(detector.HSV{H: 0, S: 0, V: 159}, detector.HSV{H: 0, S: 0, V: 174})
 
 	// 中下 - 结算界面下一步按钮
 	NextArea  = []int{535, 697, 727, 736}
-	NextColor = []color.RGBA{{0, 0, 220, 0}, {0, 0, 255, 0}}
+	NextColor = detector.MustHSVRange(detector.HSV{H: 0, S: 0, V: 220}, detector.HSV{H: 0, S: 0, V: 255})
 
 	// 右下 - 复活标志(亮)
 	RebirthLightArea  = []int{1104, 686, 1143, 718}
-	RebirthLightColor = []color.RGBA{{0, 10, 210, 0}, {24, 50, 255, 0}}
+	RebirthLightColor = detector.MustHSVRange(detector.HSV{H: 0, S: 10, V: 210}, detector.HSV{H: 24, S: 50, V: 255})
 
 	// 屏幕中右 - 设备的交互文字
 	InteractiveTextArea  = []int{930, 404, 1044, 452}
-	InteractiveTextColor = []color.RGBA{{0, 0, 0, 0}, {0, 0, 255, 0}}
+	InteractiveTextColor = detector.MustHSVRange(detector.HSV{H: 0, S: 0, V: 0}, detector.HSV{H: 0, S: 0, V: 255})
 
 	// 中上 - 击败最后一波怪后进入Boss房间的条件识别
 	BossConditionArea  = []int{494, 240, 800, 260}
-	BossConditionColor = []color.RGBA{{22, 110, 106, 0}, {45, 180, 255, 0}}
+	BossConditionColor = detector.MustHSVRange(detector.HSV{H: 22, S: 110, V: 106}, detector.HSV{H: 45, S: 180, V: 255})
 )
 
-// 获取在地下城入口的证明标志
-func GetMainArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(MainArea[0], MainArea[1], MainArea[2], MainArea[3])
-	img, _ := game.GetScreenshotMatRGB(MainArea[0], MainArea[1], w, h)
-	defer img.Close()
+var (
+	// 玩家血条的分析器
+	PlayerHealthBar = detector.NewHealthAnalyzer(
+		detector.HealthColor{State: detector.HealthRed, Range: PlayerHealthColor},
+	)
+
+	// Boss血条的分析器，灰色血条代表无敌
+	BossHealthBar = detector.NewHealthAnalyzer(
+		detector.HealthColor{State: detector.HealthRed, Range: BossHealthColor},
+		detector.HealthColor{State: detector.HealthGray, Range: BossGrayHealthColor},
+	)
+)
 
+// 获取在地下城入口的证明标志
+func GetMainArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
 	// 此区域逻辑上可获取1个紫色框体区域
-	params := detector.NewColorDetectParam(img, MainColor[0], MainColor[1], 120)
-	return colorDetector.Detect(params)
+	return Locate(game, MainArea, detector.Color(colorDetector, MainColor, 120))
 }
 
 // 获取匹配进入/进入副本按钮标志
-func GetDungeonQueueArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(DungeonQueueArea[0], DungeonQueueArea[1], DungeonQueueArea[2], DungeonQueueArea[3])
-	img, _ := game.GetScreenshotMatRGB(DungeonQueueArea[0], DungeonQueueArea[1], w, h)
-	defer img.Close()
-
+func GetDungeonQueueArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
 	// 此区域逻辑上可获取2个灰色框体区域
-	params := detector.NewColorDetectParam(img, DungeonQueueColor[0], DungeonQueueColor[1], 120)
-	return colorDetector.Detect(params)
+	return Locate(game, DungeonQueueArea, detector.Color(colorDetector, DungeonQueueColor, 120))
 }
 
 // 获取副本退出按钮标志
-func GetDungeonExitArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(DungeonReadyArea[0], DungeonReadyArea[1], DungeonReadyArea[2], DungeonReadyArea[3])
-	img, _ := game.GetScreenshotMatRGB(DungeonReadyArea[0], DungeonReadyArea[1], w, h)
-	defer img.Close()
-
-	params := detector.NewColorDetectParam(img, DungeonReadyColor[0], DungeonReadyColor[1], 50)
-	return colorDetector.Detect(params)
+func GetDungeonExitArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, DungeonReadyArea, detector.Color(colorDetector, DungeonReadyColor, 50))
 }
 
 // 获取副本进行中的标志
-func GetDungeonRunningArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(DungeonRunningArea[0], DungeonRunningArea[1], DungeonRunningArea[2], DungeonRunningArea[3])
-	img, _ := game.GetScreenshotMatRGB(DungeonRunningArea[0], DungeonRunningArea[1], w, h)
-	defer img.Close()
-
-	params := detector.NewColorDetectParam(img, DungeonRunningColor[0], DungeonRunningColor[1], 40)
-	return colorDetector.Detect(params)
+func GetDungeonRunningArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, DungeonRunningArea, detector.Color(colorDetector, DungeonRunningColor, 40))
 }
 
 // 获取玩家血条标志
-func GetPlayerHealthArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(PlayerHealthArea[0], PlayerHealthArea[1], PlayerHealthArea[2], PlayerHealthArea[3])
-	img, _ := game.GetScreenshotMatRGB(PlayerHealthArea[0], PlayerHealthArea[1], w, h)
-	defer img.Close()
-
-	params := detector.NewColorDetectParam(img, PlayerHealthColor[0], PlayerHealthColor[1], 5)
-	return colorDetector.Detect(params)
+func GetPlayerHealthArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, PlayerHealthArea, detector.Color(colorDetector, PlayerHealthColor, 5))
 }
 
 // 获取Boss红色血条
-func GetBossHealth(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(BossHealth[0], BossHealth[1], BossHealth[2], BossHealth[3])
-	img, _ := game.GetScreenshotMatRGB(BossHealth[0], BossHealth[1], w, h)
+func GetBossHealth(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, BossHealth, detector.Color(colorDetector, BossHealthColor, 1))
+}
+
+// 副本时间的识别结果低于此可信度时视为未识别
+const MinTimerConfidence = 0.6
+
+var (
+	dungeonTimerOnce sync.Once
+	dungeonTimer     detector.TimerReader // 数字模板缺失时为nil
+)
+
+// 获取右上角的副本时间，未放置数字模板(assets/digits)时始终返回false
+func GetDungeonTime(game game.Game) (detector.TimerReading, bool) {
+	dungeonTimerOnce.Do(func() {
+		reader, err := detector.NewTimerReader(assets.Digits, "digits", DungeonRunningColor)
+		if err != nil {
+			log.Printf("[检测器] 未加载副本时间的数字模板, 不再识别副本时间: %v\n", err)
+			return
+		}
+		dungeonTimer = reader
+	})
+	if dungeonTimer == nil {
+		return detector.TimerReading{}, false
+	}
+
+	area := DungeonRunningArea
+	img, err := game.GetScreenshotMatRGB(area[0], area[1], area[2]-area[0], area[3]-area[1])
+	if err != nil {
+		return detector.TimerReading{}, false
+	}
 	defer img.Close()
 
-	param := detector.NewColorDetectParam(img, BossHealthColor[0], BossHealthColor[1], 1)
-	return colorDetector.Detect(param)
+	reading, ok := dungeonTimer.Read(img)
+	return reading, ok && reading.Confidence >= MinTimerConfidence
 }
 
-// 获取Boss灰色血条（无敌状态下）
-func GetBossGrayHealth(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(BossGrayHealthArea[0], BossGrayHealthArea[1], BossGrayHealthArea[2], BossGrayHealthArea[3])
-	mat, _ := game.GetScreenshotMatRGB(BossGrayHealthArea[0], BossGrayHealthArea[1], w, h)
-	defer mat.Close()
+// 获取玩家剩余血量，未识别到血条时返回false
+func GetPlayerHealthPercent(game game.Game) (detector.HealthReading, bool) {
+	return Measure(game, PlayerHealthArea, PlayerHealthBar)
+}
 
-	param := detector.NewColorDetectParam(mat, BossGrayHealthColor[0], BossGrayHealthColor[1], 300)
-	return colorDetector.Detect(param)
+// 获取Boss剩余血量与血条状态，未识别到血条时返回false
+func GetBossHealthPercent(game game.Game) (detector.HealthReading, bool) {
+	return Measure(game, BossHealth, BossHealthBar)
 }
 
-// 获取结算画面下一步按钮标志
-func GetNextArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(NextArea[0], NextArea[1], NextArea[2], NextArea[3])
-	img, _ := game.GetScreenshotMatRGB(NextArea[0], NextArea[1], w, h)
-	defer img.Close()
+// 获取Boss灰色血条（无敌状态下）
+func GetBossGrayHealth(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, BossGrayHealthArea, detector.Color(colorDetector, BossGrayHealthColor, 300))
+}
 
+// 获取结算画面下一步按钮标志
+func GetNextArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
 	// 中间下方 - 下一步 (由于是白灰色的按钮，HSV只取高明度)
-	param := detector.NewColorDetectParam(img, NextColor[0], NextColor[1], 6500)
-	return colorDetector.Detect(param)
+	return Locate(game, NextArea, detector.Color(colorDetector, NextColor, 6500))
 }
 
 // 获取重生标志
-func GetRebirthLightArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(RebirthLightArea[0], RebirthLightArea[1], RebirthLightArea[2], RebirthLightArea[3])
-	img, _ := game.GetScreenshotMatRGB(RebirthLightArea[0], RebirthLightArea[1], w, h)
-	defer img.Close()
-
-	param := detector.NewColorDetectParam(img, RebirthLightColor[0], RebirthLightColor[1], 40)
-	return colorDetector.Detect(param)
+func GetRebirthLightArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, RebirthLightArea, detector.Color(colorDetector, RebirthLightColor, 40))
 }
 
 // 获取设备交互文本
-func GetInteractiveTextArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(InteractiveTextArea[0], InteractiveTextArea[1], InteractiveTextArea[2], InteractiveTextArea[3])
-	img, _ := game.GetScreenshotMatRGB(InteractiveTextArea[0], InteractiveTextArea[1], w, h)
-	defer img.Close()
-
-	param := detector.NewColorDetectParam(img, InteractiveTextColor[0], InteractiveTextColor[1], 5)
-	return colorDetector.Detect(param)
+func GetInteractiveTextArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, InteractiveTextArea, detector.Color(colorDetector, InteractiveTextColor, 5))
 }
 
 // 获取最后一波怪被击败的标志
-func GetBossConditionArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(BossConditionArea[0], BossConditionArea[1], BossConditionArea[2], BossConditionArea[3])
-	img, _ := game.GetScreenshotMatRGB(BossConditionArea[0], BossConditionArea[1], w, h)
+func GetBossConditionArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return Locate(game, BossConditionArea, detector.Color(colorDetector, BossConditionColor, 800))
+}
+
+// 在 area(x1, y1, x2, y2) 区域内识别，结果为窗口坐标
+func Locate(game game.Game, area []int, d detector.Detector) ([]detector.Detection, bool) {
+	return detector.Locate(&game, image.Rect(area[0], area[1], area[2], area[3]), d)
+}
+
+// 分析 area(x1, y1, x2, y2) 区域内的血条
+func Measure(game game.Game, area []int, analyzer detector.HealthAnalyzer) (detector.HealthReading, bool) {
+	img, err := game.GetScreenshotMatRGB(area[0], area[1], area[2]-area[0], area[3]-area[1])
+	if err != nil {
+		return detector.HealthReading{State: detector.HealthNone}, false
+	}
 	defer img.Close()
 
-	param := detector.NewColorDetectParam(img, BossConditionColor[0], BossConditionColor[1], 800)
-	return colorDetector.Detect(param)
+	reading := analyzer.Analyze(img)
+	return reading, reading.State != detector.HealthNone
 }
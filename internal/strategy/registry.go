@@ -1,32 +1,170 @@
 package strategy
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"star-map-tool/internal/pkg/utils"
+	"strings"
+)
 
 type Registry struct {
 	strategies map[string]Strategy
+	aliases    map[string]Strategy // 别名(小写) -> 策略
+	enabled    map[string]bool
+	keys       []string // 注册顺序
+}
+
+// 各策略包在 init() 中注册到这里，由启动器决定启用哪些策略
+var DefaultRegistry = NewRegistry()
+
+var ErrStrategyNotFound = errors.New("当前选择的地图尚未支持")
+
+// 查找策略失败时返回，附带最接近的已注册策略
+type NotFoundError struct {
+	Name        string
+	Mode        string
+	Suggestions []string // 格式: 地图(模式)
+	Ambiguous   bool     // 未指定模式且地图有多种模式
+}
+
+func (e *NotFoundError) Error() string {
+	target := e.Name
+	if e.Mode != "" {
+		target = fmt.Sprintf("%s(%s)", e.Name, e.Mode)
+	}
+	if e.Ambiguous {
+		return fmt.Sprintf("地图 %s 有多种模式, 请指定模式: %s", target, strings.Join(e.Suggestions, "、"))
+	}
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("地图 %s 尚未支持", target)
+	}
+	return fmt.Sprintf("地图 %s 尚未支持, 是否想要: %s ?", target, strings.Join(e.Suggestions, "、"))
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrStrategyNotFound
+}
+
+func Register(strategy Strategy) {
+	DefaultRegistry.Register(strategy)
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
 		strategies: make(map[string]Strategy),
+		aliases:    make(map[string]Strategy),
+		enabled:    make(map[string]bool),
 	}
 }
 
 func (r *Registry) Register(strategy Strategy) {
-	key := fmt.Sprintf("%s-%s", strategy.GetName(), strategy.GetMode())
+	key := getKey(strategy.GetName(), strategy.GetMode())
+	if _, ok := r.strategies[key]; ok {
+		panic(fmt.Sprintf("策略重复注册: %s", key))
+	}
+	for _, alias := range strategy.GetMetadata().Aliases {
+		alias = strings.ToLower(alias)
+		if s, ok := r.aliases[alias]; ok {
+			panic(fmt.Sprintf("策略别名重复: %s (%s、%s)", alias, key, getKey(s.GetName(), s.GetMode())))
+		}
+		r.aliases[alias] = strategy
+	}
 	r.strategies[key] = strategy
+	r.keys = append(r.keys, key)
 }
 
-func (r *Registry) GetStrategy(name string, mode string) (*Strategy, bool) {
-	key := fmt.Sprintf("%s-%s", name, mode)
-	strategy, ok := r.strategies[key]
-	return &strategy, ok
+// 依次按 地图+模式、别名、地图(仅有一种模式时) 查找策略
+func (r *Registry) GetStrategy(name string, mode string) (Strategy, error) {
+	if strategy, ok := r.strategies[getKey(name, mode)]; ok {
+		return strategy, nil
+	}
+	if strategy, ok := r.aliases[strings.ToLower(name)]; ok && (mode == "" || mode == strategy.GetMode()) {
+		return strategy, nil
+	}
+	if mode == "" {
+		var matched []Strategy
+		var labels []string
+		for _, key := range r.keys {
+			if s := r.strategies[key]; s.GetName() == name {
+				matched = append(matched, s)
+				labels = append(labels, fmt.Sprintf("%s(%s)", s.GetName(), s.GetMode()))
+			}
+		}
+		if len(matched) == 1 {
+			return matched[0], nil
+		} else if len(matched) > 1 {
+			return nil, &NotFoundError{Name: name, Suggestions: labels, Ambiguous: true}
+		}
+	}
+	return nil, &NotFoundError{Name: name, Mode: mode, Suggestions: r.suggest(name, mode)}
 }
 
+// 按注册顺序返回全部策略
 func (r *Registry) GetStrategyList() []Strategy {
-	strategies := make([]Strategy, 0, len(r.strategies))
-	for _, strategy := range r.strategies {
-		strategies = append(strategies, strategy)
+	strategies := make([]Strategy, 0, len(r.keys))
+	for _, key := range r.keys {
+		strategies = append(strategies, r.strategies[key])
 	}
 	return strategies
 }
+
+// 启用或停用策略，支持别名
+func (r *Registry) SetEnabled(name string, mode string, enabled bool) error {
+	strategy, err := r.GetStrategy(name, mode)
+	if err != nil {
+		return err
+	}
+	r.enabled[getKey(strategy.GetName(), strategy.GetMode())] = enabled
+	return nil
+}
+
+func (r *Registry) IsEnabled(name string, mode string) bool {
+	return r.enabled[getKey(name, mode)]
+}
+
+// 找出与输入最接近的最多3个策略
+func (r *Registry) suggest(name string, mode string) []string {
+	const limit = 3
+
+	query, lowerName := strings.ToLower(name+mode), strings.ToLower(name)
+	type candidate struct {
+		strategy Strategy
+		distance int
+	}
+	var candidates []candidate
+	for _, key := range r.keys {
+		strategy := r.strategies[key]
+		distance := utils.Levenshtein(query, strings.ToLower(strategy.GetName()+strategy.GetMode()))
+		if mode == "" {
+			distance = min(distance, utils.Levenshtein(query, strings.ToLower(strategy.GetName())))
+		}
+		for _, alias := range strategy.GetMetadata().Aliases {
+			distance = min(distance, utils.Levenshtein(lowerName, strings.ToLower(alias)))
+		}
+		if target := strings.ToLower(strategy.GetName()); lowerName != "" &&
+			(strings.Contains(target, lowerName) || strings.Contains(lowerName, target)) {
+			distance = min(distance, 1) // 地图名称部分匹配
+		}
+
+		// 编辑距离超过输入长度一半的视为无关
+		if distance*2 > len([]rune(query)) && distance > 1 {
+			continue
+		}
+		candidates = append(candidates, candidate{strategy: strategy, distance: distance})
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return a.distance - b.distance
+	})
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		s := candidates[i].strategy
+		suggestions = append(suggestions, fmt.Sprintf("%s(%s)", s.GetName(), s.GetMode()))
+	}
+	return suggestions
+}
+
+func getKey(name string, mode string) string {
+	return fmt.Sprintf("%s-%s", name, mode)
+}
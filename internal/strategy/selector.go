@@ -12,11 +12,12 @@ func NewSelector(registry *Registry) *Selector {
 	}
 }
 
-func (s *Selector) Select(name string, mode string) *Strategy {
-	strategy, ok := s.registry.GetStrategy(name, mode)
-	if !ok {
-		panic("当前选择的地图尚未支持!")
+// 未找到时返回 *NotFoundError，可通过 errors.Is(err, ErrStrategyNotFound) 判断
+func (s *Selector) Select(name string, mode string) (Strategy, error) {
+	strategy, err := s.registry.GetStrategy(name, mode)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("[选择器] 当前选择的地图: %s 模式: %s\n", name, mode)
-	return strategy
+	log.Printf("[选择器] 当前选择的地图: %s 模式: %s\n", strategy.GetName(), strategy.GetMode())
+	return strategy, nil
 }
@@ -1,50 +1,33 @@
 package clan3
 
 import (
-	"image"
-	"image/color"
 	"star-map-tool/internal/detector"
 	"star-map-tool/internal/game"
-	"star-map-tool/internal/pkg/utils"
+	"star-map-tool/internal/strategy/preset"
 )
 
 var (
 	// 屏幕中间 - 地面阵法花纹
 	PatternArea  = []int{640, 150, 750, 530}
-	PatternColor = []color.RGBA{{85, 105, 213, 0}, {255, 255, 255, 0}}
+	PatternColor = detector.MustHSVRange(detector.HSV{H: 85, S: 105, V: 213}, detector.HSV{H: 179, S: 255, V: 255})
 
 	// 屏幕中间 - 地面阵法花纹（使用后）
 	PatternUsedArea  = []int{640, 150, 750, 530}
-	PatternUsedColor = []color.RGBA{{105, 30, 213, 0}, {255, 115, 255, 0}}
+	PatternUsedColor = detector.MustHSVRange(detector.HSV{H: 105, S: 30, V: 213}, detector.HSV{H: 179, S: 115, V: 255})
 
 	// 中上 - 必杀剑技能提示
 	SwordArea  = []int{450, 220, 850, 300}
-	SwordColor = []color.RGBA{{22, 110, 106, 0}, {45, 180, 255, 0}}
+	SwordColor = detector.MustHSVRange(detector.HSV{H: 22, S: 110, V: 106}, detector.HSV{H: 45, S: 180, V: 255})
 )
 
-func GetPatternArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(PatternArea[0], PatternArea[1], PatternArea[2], PatternArea[3])
-	img, _ := game.GetScreenshotMatRGB(PatternArea[0], PatternArea[1], w, h)
-	defer img.Close()
-
-	param := detector.NewColorDetectParam(img, PatternColor[0], PatternColor[1], 600)
-	return colorDetector.Detect(param)
+func GetPatternArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return preset.Locate(game, PatternArea, detector.Color(colorDetector, PatternColor, 600))
 }
 
-func GetPatternUsedArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(PatternUsedArea[0], PatternUsedArea[1], PatternUsedArea[2], PatternUsedArea[3])
-	img, _ := game.GetScreenshotMatRGB(PatternUsedArea[0], PatternUsedArea[1], w, h)
-	defer img.Close()
-
-	param := detector.NewColorDetectParam(img, PatternColor[0], PatternColor[1], 600)
-	return colorDetector.Detect(param)
+func GetPatternUsedArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return preset.Locate(game, PatternUsedArea, detector.Color(colorDetector, PatternColor, 600))
 }
 
-func GetSwordArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(SwordArea[0], SwordArea[1], SwordArea[2], SwordArea[3])
-	img, _ := game.GetScreenshotMatRGB(SwordArea[0], SwordArea[1], w, h)
-	defer img.Close()
-
-	param := detector.NewColorDetectParam(img, SwordColor[0], SwordColor[1], 100)
-	return colorDetector.Detect(param)
+func GetSwordArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return preset.Locate(game, SwordArea, detector.Color(colorDetector, SwordColor, 100))
 }
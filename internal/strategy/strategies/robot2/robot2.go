@@ -5,202 +5,102 @@ import (
 	"image"
 	"log"
 	"math"
-	"star-map-tool/internal/detector"
 	"star-map-tool/internal/pkg/script"
 	"star-map-tool/internal/pkg/sleeper"
 	"star-map-tool/internal/pkg/utils"
 	"star-map-tool/internal/strategy"
 	"star-map-tool/internal/strategy/preset"
-	"sync/atomic"
 	"time"
-
-	"github.com/go-vgo/robotgo"
 )
 
 // 策略算是单例的，上下文每次执行都是新的
 type StrategyImpl struct {
-	enable  int32
-	context *strategy.StrategyContext
+	strategy.BaseStrategy
+
+	script script.Script
+}
 
-	colorDetector detector.ColorDetector
-	script        script.Script
+func init() {
+	strategy.Register(NewStrategyImpl())
 }
 
 func NewStrategyImpl() strategy.Strategy {
 	return &StrategyImpl{
-		enable: 1,
+		BaseStrategy: strategy.NewBaseStrategy("无音之都", "困难"),
 	}
 }
 
-func (s *StrategyImpl) GetName() string {
-	return "无音之都"
-}
-
-func (s *StrategyImpl) GetMode() string {
-	return "困难"
-}
-
-func (s *StrategyImpl) IsEnable() bool {
-	return atomic.LoadInt32(&s.enable) == 1
-}
-
-func (s *StrategyImpl) Enable() {
-	atomic.StoreInt32(&s.enable, 1)
-}
-
-func (s *StrategyImpl) Disable(reason int32) {
-	// -1: 终止(超时)、-2: 执行失败、-3: 死亡、0: 执行结束、1: 可用
-	atomic.StoreInt32(&s.enable, reason)
-}
-
-func (s *StrategyImpl) StartDeathCheck(ctx *strategy.StrategyContext) {
-	atomic.StoreInt32(&ctx.DeathCheckFlag, 1) // 开启死亡检测
-}
-
-func (s *StrategyImpl) StopDeathCheck(ctx *strategy.StrategyContext) {
-	atomic.StoreInt32(&ctx.DeathCheckFlag, 0) // 关闭死亡检测
-}
-
-func (s *StrategyImpl) Init() {
-	s.colorDetector = detector.NewColorDetector()
-	s.script = script.NewDefaultScript()
-}
-
-func (s *StrategyImpl) Execute(sctx *strategy.StrategyContext, data any) bool {
-	s.context = sctx // 每次的策略上下文都是新的
-	s.context.Attrs["START_TIME"] = time.Now()
-	s.Enable()
-	go s.runDeatchCheck()
-
-	var operationList []script.Operation
-	// operationList = append(operationList, s.goToDungeon()...)
-	// operationList = append(operationList, s.startDungeon()...)
-	// operationList = append(operationList, s.handleScence1()...)
-	// operationList = append(operationList, s.handleScence2()...)
-	// operationList = append(operationList, s.handleScence3()...)
-	// operationList = append(operationList, s.handleScence4()...)
-	operationList = append(operationList, s.handleBossScence()...)
-	return s.run(operationList)
-}
-
-func (s *StrategyImpl) Abort(sign string) {
-	s.Disable(-1)
-}
-
-func (s *StrategyImpl) run(list []script.Operation) bool {
-	for _, op := range list {
-		if !s.IsEnable() {
-			s.exitDungeon()
-			return false
-		}
-		ok := op()
-		if !ok {
-			s.Disable(-2)
-			s.exitDungeon()
-			return false
-		}
+func (s *StrategyImpl) GetMetadata() strategy.Metadata {
+	return strategy.Metadata{
+		Roles:       "让出奶位",
+		Description: "部分环节存在奶量压力!", // 副本有BUG，吃不到球造成团灭
+		Timeout:     12 * time.Minute,
+		Interval:    10 * time.Second,
+		Aliases:     []string{"robot2", "wyzd2"},
 	}
-	s.Disable(0) // 让子线程有停止的机会
-	return true
 }
 
-func (s *StrategyImpl) runDeatchCheck() {
-	// 死亡处理：一般只检测途中，死亡后直接退出（如果不退出需要更复杂的操作去识别、修正行为）
-	running := false
-	for {
-		flag := atomic.LoadInt32(&s.context.DeathCheckFlag)
-		if running && flag == 0 {
-			// 逻辑内关闭死亡检测 - 通常到达BOSS战才会关闭
-			return
-		} else if !s.IsEnable() {
-			// 有其他逻辑中断策略执行，停止死亡检测
-			return
-		} else if flag == 0 {
-			sleeper.Sleep(200)
-			continue
-		}
-		if !running {
-			running = true
-		}
-
-		_, _, ok := preset.GetPlayerHealthArea(*s.context.Game, s.colorDetector)
-		flag = atomic.LoadInt32(&s.context.DeathCheckFlag)
-		if !ok && flag == 1 { // 已死亡
-			log.Printf("[%s-%s] 未检测到玩家血条,认定为已死亡(即将执行P出逻辑)\n", s.GetName(), s.GetMode())
-			running = false
-			s.Disable(-3) // 交给主线程去退出对局
-			return
-		}
-		sleeper.Sleep(200)
-	}
+func (s *StrategyImpl) Init() error {
+	s.BaseStrategy.Init()
+	s.script = script.NewDefaultScript(s.ColorDetector, func() *strategy.StrategyContext { return s.Context })
+	return nil
 }
 
-func (s *StrategyImpl) exitDungeon() {
-	enable := atomic.LoadInt32(&s.enable)
-	if enable == -2 || enable == -3 {
-		robotgo.Click() // 有可能小月卡弹框
-		sleeper.Sleep(200)
-
-		// 直接p，死亡状态按p是无效的，如果能退就退了
-		robotgo.KeyTap("p")
-		robotgo.MoveClick(794, 579)
-
-		// p不出去就点死亡时出现的退出按钮
-		robotgo.MoveClick(1179, 67)
-		robotgo.MoveClick(794, 579)
-		log.Printf("[%s-%s] 已执行副本退出逻辑\n", s.GetName(), s.GetMode())
-	}
+func (s *StrategyImpl) Execute(sctx *strategy.StrategyContext, data any) strategy.Outcome {
+	return s.Launch(sctx, func() []strategy.Scene {
+		return []strategy.Scene{
+			// {Name: "前往地下城", Operations: s.goToDungeon()},
+			// {Name: "开启地下城", Operations: s.startDungeon()},
+			// {Name: "第1个关卡", Operations: s.handleScence1()},
+			// {Name: "第2个关卡", Operations: s.handleScence2()},
+			// {Name: "第3个关卡", Operations: s.handleScence3()},
+			// {Name: "第4个关卡", Operations: s.handleScence4()},
+			{Name: "Boss关卡", Operations: s.handleBossScence()},
+		}
+	})
 }
 
 func (s *StrategyImpl) handleBossScence() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Wait(6000),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(3*time.Minute, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
-				}
-				_, _, ok := preset.GetPlayerHealthArea(*sctx.Game, s.colorDetector)
-				return ok, nil
-			}, false)
-		}, func() *strategy.StrategyContext { return s.context }),
+		s.script.WaitUntil(preset.GetPlayerHealthArea, 3*time.Minute, 1*time.Second, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Wait(3000),
 		s.script.Log(s.GetName(), s.GetMode(), "执行第Boss关卡"),
 		s.script.Move([]string{"w", "shift"}, 2_000),
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(2*time.Minute, 800*time.Millisecond, func() (bool, error) {
+			return utils.NewTicker(sctx.Ctx, 2*time.Minute, 800*time.Millisecond, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetRebirthLightArea(*sctx.Game, s.colorDetector)
+				_, ok := preset.GetRebirthLightArea(*sctx.Game, s.ColorDetector)
 				if ok { // 人机打的太慢了
-					robotgo.MoveClick(1123, 700)
-					sleeper.Sleep(6_000)
+					s.Input().MoveClick(1123, 700)
+					sleeper.Sleep(sctx.Ctx, 6_000)
 				}
-				_, _, ok = preset.GetBossGrayHealth(*sctx.Game, s.colorDetector)
+				_, ok = preset.GetBossGrayHealth(*sctx.Game, s.ColorDetector)
 				return ok, nil
 			}, false)
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Wait(4500),
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
 			var times int32 = 0
-			utils.NewTicker(3*time.Minute, 100*time.Millisecond, func() (bool, error) {
+			utils.NewTicker(sctx.Ctx, 3*time.Minute, 100*time.Millisecond, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				rectList, _, ok := GetSphereArea(*sctx.Game, s.colorDetector)
-				if !ok || len(rectList) <= 0 {
-					script.ChangeCameraAngleForX(x, y, -50, 3.24)
-					sleeper.SleepBusyLoop(500)
+				spheres, ok := GetSphereArea(*sctx.Game, s.ColorDetector)
+				if !ok || len(spheres) <= 0 {
+					script.ChangeCameraAngleForX(s.Input(), x, y, -50, 3.24)
+					sleeper.SleepBusyLoop(sctx.Ctx, 500)
 
-					_, _, ok = preset.GetBossHealth(*sctx.Game, s.colorDetector)
+					_, ok = preset.GetBossHealth(*sctx.Game, s.ColorDetector)
 					// return times >= 4, nil
 					return ok, nil
 				}
 				if times == 0 {
-					robotgo.KeyTap("q")
-					sleeper.SleepBusyLoop(400)
+					s.Input().KeyTap("q")
+					sleeper.SleepBusyLoop(sctx.Ctx, 400)
 				}
 				times++
 
@@ -208,8 +108,8 @@ func (s *StrategyImpl) handleBossScence() []script.Operation {
 				var target image.Rectangle
 				minXDiff := 999
 				flag := false
-				for _, item := range rectList {
-					center := utils.GetCenter(item)
+				for _, item := range spheres {
+					center := utils.GetCenter(item.Rect)
 					xdiff := center.X - x
 					ydiff := center.Y - y // 过滤掉 y diff >= 0 的（只找面前的, <0）
 					if ydiff >= 0 {
@@ -217,58 +117,58 @@ func (s *StrategyImpl) handleBossScence() []script.Operation {
 					}
 					if math.Abs(float64(xdiff)) < math.Abs(float64(minXDiff)) {
 						minXDiff = xdiff
-						target = item
+						target = item.Rect
 						flag = true
 					}
 				}
 				if !flag {
-					script.ChangeCameraAngleForX(x, y, -50, 3.24)
-					sleeper.SleepBusyLoop(500)
+					script.ChangeCameraAngleForX(s.Input(), x, y, -50, 3.24)
+					sleeper.SleepBusyLoop(sctx.Ctx, 500)
 					return false, nil
 				}
 
 				rect := target
 				center := utils.GetCenter(rect)
 				angle := utils.GetAngle(image.Point{X: x, Y: y}, center, 14.2)
-				script.ChangeCameraAngleForX(x, y, angle, 3.24)
-				sleeper.Sleep(300)
-				robotgo.KeyDown("w")
+				script.ChangeCameraAngleForX(s.Input(), x, y, angle, 3.24)
+				sleeper.Sleep(sctx.Ctx, 300)
+				s.Input().KeyDown("w")
 				if math.Abs(float64(angle)) <= 3 {
-					sleeper.Sleep(1700)
+					sleeper.Sleep(sctx.Ctx, 1700)
 				} else {
-					sleeper.SleepBusyLoop(700)
+					sleeper.SleepBusyLoop(sctx.Ctx, 700)
 				}
-				robotgo.KeyUp("w")
+				s.Input().KeyUp("w")
 				return false, nil
 			}, false)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(10*time.Minute, 1*time.Second, func() (bool, error) {
+			return utils.NewTicker(sctx.Ctx, 10*time.Minute, 1*time.Second, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetRebirthLightArea(*sctx.Game, s.colorDetector)
+				_, ok := preset.GetRebirthLightArea(*sctx.Game, s.ColorDetector)
 				if ok { // 人机打的太慢了
-					robotgo.MoveClick(1123, 700)
-					sleeper.Sleep(6_000)
+					s.Input().MoveClick(1123, 700)
+					sleeper.Sleep(sctx.Ctx, 6_000)
 				}
 				// 不再检查boss血条，这个图环境干扰容易误判
-				_, _, ok = preset.GetNextArea(*sctx.Game, s.colorDetector)
+				_, ok = preset.GetNextArea(*sctx.Game, s.ColorDetector)
 				if ok {
-					robotgo.MoveClick(635, 715)
-					sleeper.Sleep(200)
-					robotgo.MoveClick(935, 735)
+					s.Input().MoveClick(635, 715)
+					sleeper.Sleep(sctx.Ctx, 200)
+					s.Input().MoveClick(935, 735)
 				}
 				return ok, nil
 			}, false)
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Log(s.GetName(), s.GetMode(), "检测到下一步按钮,正在正常退出副本..."),
 	}
 }
 
 func (s *StrategyImpl) handleScence4() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Wait(500),
 		s.script.Log(s.GetName(), s.GetMode(), "正在前往第4个关卡"),
@@ -279,31 +179,23 @@ func (s *StrategyImpl) handleScence4() []script.Operation {
 		s.script.Move([]string{"w", "shift"}, 7_500),
 		s.script.ChangeCameraAngleForX(x, y, -67, 3.24),
 		s.script.MoveAndOnce([]string{"w", "shift"}, 4_000, func(sc *strategy.StrategyContext) (bool, error) {
-			robotgo.KeyTap("space")
-			sleeper.Sleep(500)
-			robotgo.KeyTap("space")
-			sleeper.Sleep(500)
-			robotgo.KeyTap("space")
-			sleeper.Sleep(500)
-			robotgo.KeyTap("space")
+			s.Input().KeyTap("space")
+			sleeper.Sleep(sc.Ctx, 500)
+			s.Input().KeyTap("space")
+			sleeper.Sleep(sc.Ctx, 500)
+			s.Input().KeyTap("space")
+			sleeper.Sleep(sc.Ctx, 500)
+			s.Input().KeyTap("space")
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Log(s.GetName(), s.GetMode(), "执行第4个关卡(特征:人型)"),
 		s.script.TapOnce("h"),
-		s.script.ExecTask(func(sc *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(10*time.Minute, 300*time.Millisecond, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
-				}
-				_, _, ok := preset.GetBossConditionArea(*sc.Game, s.colorDetector)
-				return ok, nil
-			}, false)
-		}, func() *strategy.StrategyContext { return s.context }),
+		s.script.WaitUntil(preset.GetBossConditionArea, 10*time.Minute, 300*time.Millisecond, func() *strategy.StrategyContext { return s.Context }),
 	}
 }
 
 func (s *StrategyImpl) handleScence3() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Wait(500),
 		s.script.Log(s.GetName(), s.GetMode(), "正在前往第3个关卡"),
@@ -312,87 +204,87 @@ func (s *StrategyImpl) handleScence3() []script.Operation {
 		s.script.Wait(5_000),
 		s.script.ChangeCameraAngleForX(x, y, 4, 3.24),
 		s.script.MoveAndOnce([]string{"w", "shift"}, 10_000, func(sc *strategy.StrategyContext) (bool, error) {
-			sleeper.Sleep(6000)
+			sleeper.Sleep(sc.Ctx, 6000)
 
-			robotgo.KeyTap("e")
-			sleeper.SleepBusyLoop(600)
-			robotgo.KeyTap("e")
-			sleeper.Sleep(1000)
+			s.Input().KeyTap("e")
+			sleeper.SleepBusyLoop(sc.Ctx, 600)
+			s.Input().KeyTap("e")
+			sleeper.Sleep(sc.Ctx, 1000)
 
-			robotgo.KeyUp("shift")
-			robotgo.KeyDown("shift")
+			s.Input().KeyUp("shift")
+			s.Input().KeyDown("shift")
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Log(s.GetName(), s.GetMode(), "执行第3个关卡(特征:人形)"),
 		s.script.TapOnce("shift"),
 		s.script.Wait(1_000),
 		s.script.Move([]string{"d", "shift"}, 5_000),
 		s.script.ExecTask(func(sc *strategy.StrategyContext) (bool, error) {
 			s.StopDeathCheck(sc)
-			utils.NewTicker(50*time.Second, 1*time.Second, func() (bool, error) {
+			utils.NewTicker(sc.Ctx, 50*time.Second, 1*time.Second, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetRebirthLightArea(*sc.Game, s.colorDetector)
+				_, ok := preset.GetRebirthLightArea(*sc.Game, s.ColorDetector)
 				if ok {
-					robotgo.MoveClick(1123, 700)
-					sleeper.Sleep(6_000)
+					s.Input().MoveClick(1123, 700)
+					sleeper.Sleep(sc.Ctx, 6_000)
 				}
 				return false, nil
 			}, true)
 			// 第4个关卡开始就不需要死亡检测了
 			// s.StartDeathCheck(sc)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 	}
 }
 
 func (s *StrategyImpl) handleScence2() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Wait(700),
 		s.script.Log(s.GetName(), s.GetMode(), "正在前往第2个关卡"),
 		s.script.Move([]string{"w", "shift"}, 13_000),
 		s.script.MoveAndOnce([]string{"a"}, 3_000, func(sc *strategy.StrategyContext) (bool, error) {
-			robotgo.KeyTap("space")
-			sleeper.Sleep(200)
+			s.Input().KeyTap("space")
+			sleeper.Sleep(sc.Ctx, 200)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Move([]string{"d"}, 400),
 		s.script.ChangeCameraAngleForX(x, y, 55, 3.24),
 		s.script.MoveAndOnce([]string{"w", "shift"}, 5_000, func(sc *strategy.StrategyContext) (bool, error) {
-			sleeper.Sleep(1_000)
-			robotgo.KeyTap("space")
-			sleeper.Sleep(500)
-			robotgo.KeyTap("space")
-			sleeper.Sleep(500)
-			robotgo.KeyTap("space")
-			sleeper.Sleep(200)
+			sleeper.Sleep(sc.Ctx, 1_000)
+			s.Input().KeyTap("space")
+			sleeper.Sleep(sc.Ctx, 500)
+			s.Input().KeyTap("space")
+			sleeper.Sleep(sc.Ctx, 500)
+			s.Input().KeyTap("space")
+			sleeper.Sleep(sc.Ctx, 200)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Log(s.GetName(), s.GetMode(), "执行第2个关卡(特征:人型)"),
 		s.script.Move([]string{"a"}, 1_500),
 		s.script.ExecTask(func(sc *strategy.StrategyContext) (bool, error) {
 			s.StopDeathCheck(sc)
-			utils.NewTicker(50*time.Second, 1*time.Second, func() (bool, error) {
+			utils.NewTicker(sc.Ctx, 50*time.Second, 1*time.Second, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetRebirthLightArea(*sc.Game, s.colorDetector)
+				_, ok := preset.GetRebirthLightArea(*sc.Game, s.ColorDetector)
 				if ok {
-					robotgo.MoveClick(1123, 700)
-					sleeper.Sleep(6_000)
+					s.Input().MoveClick(1123, 700)
+					sleeper.Sleep(sc.Ctx, 6_000)
 				}
 				return false, nil
 			}, true)
 			s.StartDeathCheck(sc)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 	}
 }
 
 func (s *StrategyImpl) handleScence1() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Log(s.GetName(), s.GetMode(), "执行第1个关卡(特征:人型)"),
 		s.script.Wait(2000),
@@ -405,20 +297,20 @@ func (s *StrategyImpl) handleScence1() []script.Operation {
 		s.script.Move([]string{"d", "shift"}, 2_000),
 		s.script.ExecTask(func(sc *strategy.StrategyContext) (bool, error) {
 			s.StopDeathCheck(sc)
-			utils.NewTicker(40*time.Second, 1*time.Second, func() (bool, error) {
+			utils.NewTicker(sc.Ctx, 40*time.Second, 1*time.Second, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetRebirthLightArea(*sc.Game, s.colorDetector)
+				_, ok := preset.GetRebirthLightArea(*sc.Game, s.ColorDetector)
 				if ok {
-					robotgo.MoveClick(1123, 700)
-					sleeper.Sleep(6_000)
+					s.Input().MoveClick(1123, 700)
+					sleeper.Sleep(sc.Ctx, 6_000)
 				}
 				return false, nil
 			}, true)
 			s.StartDeathCheck(sc)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Move([]string{"s"}, 1_500),
 		s.script.Move([]string{"w"}, 2_200),
 		s.script.ChangeCameraAngleForX(x, y, 90, 3.24),
@@ -428,21 +320,8 @@ func (s *StrategyImpl) handleScence1() []script.Operation {
 func (s *StrategyImpl) startDungeon() []script.Operation {
 	return []script.Operation{
 		s.script.Wait(2000),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			ret, err := utils.NewTicker(20*time.Second, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
-				}
-				_, _, ok := preset.GetDungeonExitArea(*sctx.Game, s.colorDetector) // todo: 这里有问题
-				return ok, nil
-			}, true)
-			if !ret {
-				log.Printf("[%s-%s] 检测到未进入地下城\n", s.GetName(), s.GetMode())
-			} else {
-				log.Printf("[%s-%s] 检测到已进入地下城\n", s.GetName(), s.GetMode())
-			}
-			return ret, err
-		}, func() *strategy.StrategyContext { return s.context }),
+		s.script.WaitUntil(preset.GetDungeonExitArea, 20*time.Second, 1*time.Second, func() *strategy.StrategyContext { return s.Context }), // todo: 这里有问题
+		s.script.Log(s.GetName(), s.GetMode(), "检测到已进入地下城"),
 		s.script.Wait(3000),
 		s.script.Move([]string{"a"}, 400),
 		s.script.Move([]string{"w"}, 900),
@@ -450,64 +329,53 @@ func (s *StrategyImpl) startDungeon() []script.Operation {
 		s.script.Log(s.GetName(), s.GetMode(), "正在开启地下城..."),
 		s.script.Wait(200),
 		s.script.MouseMoveClick(810, 665),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			ret, err := utils.NewTicker(15*time.Second, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
-				}
-				_, _, ok := preset.GetDungeonRunningArea(*sctx.Game, s.colorDetector)
-				return ok, nil
-			}, true)
-			if !ret {
-				log.Printf("[%s-%s] 检测到未开启地下城\n", s.GetName(), s.GetMode())
-			} else {
-				log.Printf("[%s-%s] 检测到已开启地下城\n", s.GetName(), s.GetMode())
-			}
-			return ret, err
-		}, func() *strategy.StrategyContext { return s.context }),
+		s.script.WaitUntil(preset.GetDungeonRunningArea, 15*time.Second, 1*time.Second, func() *strategy.StrategyContext { return s.Context }),
+		s.script.Log(s.GetName(), s.GetMode(), "检测到已开启地下城"),
 		s.script.ExecTask(func(scxt *strategy.StrategyContext) (bool, error) {
 			s.StartDeathCheck(scxt)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 	}
 }
 
 func (s *StrategyImpl) goToDungeon() []script.Operation {
+	// 检测到异常队伍时先退出队伍，再重新进入
 	return []script.Operation{
-		// 检查是否在地下城入口
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(10*time.Second, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
+		script.Retry(script.Sequence(
+			// 检查是否在地下城入口
+			s.script.WaitUntil(preset.GetMainArea, 10*time.Second, 1*time.Second, func() *strategy.StrategyContext { return s.Context }),
+			s.script.TapOnce("f"),
+			s.script.Wait(200),
+			s.script.MouseMoveClick(124, 208),
+			s.script.Wait(200),
+			s.script.MouseMoveClick(1000, 690),
+			s.script.Wait(200),
+			s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
+				if list, ok := preset.GetDungeonQueueArea(*sctx.Game, s.ColorDetector); !ok || len(list) != 2 {
+					return false, nil
 				}
-				_, _, ok := preset.GetMainArea(*sctx.Game, s.colorDetector)
-				return ok, nil
-			}, true)
-		}, func() *strategy.StrategyContext { return s.context }),
-		s.script.TapOnce("f"),
-		s.script.Wait(200),
-		s.script.MouseMoveClick(124, 208),
-		s.script.Wait(200),
-		s.script.MouseMoveClick(1000, 690),
-		s.script.Wait(200),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			if _, sizeList, ok := preset.GetDungeonQueueArea(*sctx.Game, s.colorDetector); !ok || len(sizeList) != 2 {
-				return false, nil
-			}
-			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
-		s.script.MouseMoveClick(1185, 735),
-		s.script.MouseMove(0, 0),
-		s.script.Wait(3 * 1000),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			if _, sizeList, ok := preset.GetDungeonQueueArea(*sctx.Game, s.colorDetector); ok && len(sizeList) == 2 {
-				log.Printf("[%s-%s] 检测到异常队伍,正在执行退出队伍操作...\n", s.GetName(), s.GetMode())
-				robotgo.KeyTap("esc")
-				script.HandleAbnormalTeam(sctx.Game)
-				s.run(s.goToDungeon())
-			}
-			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+				return true, nil
+			}, func() *strategy.StrategyContext { return s.Context }),
+			s.script.MouseMoveClick(1185, 735),
+			s.script.MouseMove(0, 0),
+			s.script.Wait(3*1000),
+			script.Fallback(
+				// 检查是否进入了异常队伍
+				s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
+					if list, ok := preset.GetDungeonQueueArea(*sctx.Game, s.ColorDetector); ok && len(list) == 2 {
+						log.Printf("[%s-%s] 检测到异常队伍,正在执行退出队伍操作...\n", s.GetName(), s.GetMode())
+						return false, nil
+					}
+					return true, nil
+				}, func() *strategy.StrategyContext { return s.Context }),
+				// 退出队伍后由 Retry 重新进入
+				s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
+					s.Input().KeyTap("esc")
+					script.HandleAbnormalTeam(sctx.Game)
+					return false, nil
+				}, func() *strategy.StrategyContext { return s.Context }),
+			),
+		), 3, 2*time.Second),
 		s.script.Log(s.GetName(), s.GetMode(), "正在进入地下城..."),
 	}
 }
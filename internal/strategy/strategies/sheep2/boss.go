@@ -5,22 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
 	"log"
 	"star-map-tool/internal/detector"
 	"star-map-tool/internal/pkg/script"
 	"star-map-tool/internal/pkg/sleeper"
 	"star-map-tool/internal/pkg/utils"
 	"star-map-tool/internal/strategy"
+	"star-map-tool/internal/strategy/preset"
 	"time"
-
-	"github.com/go-vgo/robotgo"
 )
 
-// -------------------------------------------------- 应对BOSS战 ----------------------------------------------------
-
-var classes = []string{"BOSS_REST", "KEY_FLOWER", "KEY_MOON", "KEY_LEAF", "KEY_SWORD"}
-
 // -------------------------------------------------- 应对BOSS战（找墙体） ----------------------------------------------------
 
 func GotoWall(s *StrategyImpl, sctx *strategy.StrategyContext, direction int, duration int) bool {
@@ -44,8 +38,8 @@ func GotoWall(s *StrategyImpl, sctx *strategy.StrategyContext, direction int, du
 				defer mat.Close()
 
 				// 找红色血条
-				param := detector.NewColorDetectParam(mat, color.RGBA{0, 236, 244, 0}, color.RGBA{25, 255, 255, 0}, 300)
-				if _, _, ok := s.colorDetector.Detect(param); !ok {
+				param := detector.NewColorDetectParam(mat, preset.BossHealthColor, 300)
+				if _, ok := s.ColorDetector.Detect(param); !ok {
 					continue
 				}
 				log.Printf("[%s-%s] 场地交互已完成,恢复对Boss的攻击\n", s.GetName(), s.GetMode())
@@ -56,7 +50,7 @@ func GotoWall(s *StrategyImpl, sctx *strategy.StrategyContext, direction int, du
 	}()
 
 	go func() {
-		script.MoveSide(direction, 5_000, 1, flag2)
+		script.MoveSide(s.Context.Ctx, s.Input(), direction, 5_000, 1, flag2)
 	}()
 
 	ticker := time.NewTicker(100 * time.Millisecond)
@@ -81,13 +75,13 @@ func GotoWall(s *StrategyImpl, sctx *strategy.StrategyContext, direction int, du
 }
 
 func findDirectionOfWall(s *StrategyImpl, sctx *strategy.StrategyContext) (int, bool) {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 
 	img, _ := sctx.Game.GetScreenshotMatRGB()
 	defer img.Close()
 
-	param := detector.NewColorDetectParam(img, color.RGBA{130, 90, 136, 0}, color.RGBA{149, 252, 210, 0}, 300)
-	wallRect, _, ok := s.colorDetector.Detect(param)
+	param := detector.NewColorDetectParam(img, WallColor, 300)
+	walls, ok := s.ColorDetector.Detect(param)
 
 	if ok {
 		log.Printf("[%s-%s] 检测墙体位置成功\n", s.GetName(), s.GetMode())
@@ -95,7 +89,7 @@ func findDirectionOfWall(s *StrategyImpl, sctx *strategy.StrategyContext) (int,
 		log.Printf("[%s-%s] 检测墙体位置失败\n", s.GetName(), s.GetMode())
 		return 999, false
 	}
-	wall := wallRect[0]
+	wall := walls[0].Rect
 
 	// 计算高度
 	height := wall.Max.Y - wall.Min.Y
@@ -104,7 +98,7 @@ func findDirectionOfWall(s *StrategyImpl, sctx *strategy.StrategyContext) (int,
 	center := utils.GetCenter(wall)
 	angle := utils.GetAngle(image.Point{X: x, Y: y}, center, 14.2)
 	log.Printf("[%s-%s] 检测墙体高度为:%d 角度:%d\n", s.GetName(), s.GetMode(), height, angle)
-	script.ChangeCameraAngleForX(x, y, angle, 3.24)
+	script.ChangeCameraAngleForX(s.Input(), x, y, angle, 3.24)
 
 	direction := 1
 	if angle < 0 {
@@ -131,10 +125,10 @@ func GotoTaskKey(s *StrategyImpl, sctx *strategy.StrategyContext, duration int)
 		return true
 	}
 
-	x, y := robotgo.Location()
-	script.ChangeCameraAngleForY(x, y, 65, 7.8)
-	script.Scroll(10, "up")
-	script.Scroll(7, "down") // 控制视角去识别武器
+	x, y := s.Input().Location()
+	script.ChangeCameraAngleForY(s.Input(), x, y, 65, 7.8)
+	script.Scroll(s.Input(), 10, "up")
+	script.Scroll(s.Input(), 7, "down") // 控制视角去识别武器
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(duration)*time.Millisecond)
 	flag1 := make(chan int, 1) // 主线程向子线程写入停止执行命令
@@ -165,7 +159,7 @@ func GotoTaskKey(s *StrategyImpl, sctx *strategy.StrategyContext, duration int)
 	}()
 
 	go func() {
-		script.MoveSide(direction, 5_000, 0, flag2) // 跑太快会错过检测
+		script.MoveSide(s.Context.Ctx, s.Input(), direction, 5_000, 0, flag2) // 跑太快会错过检测
 	}()
 
 	ticker := time.NewTicker(100 * time.Millisecond)
@@ -179,9 +173,9 @@ func GotoTaskKey(s *StrategyImpl, sctx *strategy.StrategyContext, duration int)
 			}
 			flag2 <- 0
 			if errors.Is(ctx.Err(), context.Canceled) {
-				script.Scroll(10, "up")
-				script.Scroll(5, "down")
-				script.ChangeCameraAngleForY(x, y, -65, 7.8)
+				script.Scroll(s.Input(), 10, "up")
+				script.Scroll(s.Input(), 5, "down")
+				script.ChangeCameraAngleForY(s.Input(), x, y, -65, 7.8)
 			}
 			return errors.Is(ctx.Err(), context.Canceled)
 		case <-ticker.C:
@@ -199,19 +193,19 @@ func findTaskKey(s *StrategyImpl, sctx *strategy.StrategyContext, targetList []i
 	mat, _ := sctx.Game.GetScreenshotMatRGB()
 	defer mat.Close()
 
-	param := detector.NewDNNDetectParam(mat, 0.5, 0.45)
-	_, _, ok := s.dnnDetector.Detect(param, targetList...)
+	param := detector.NewDNNDefaultParam(mat)
+	_, ok := s.dnnDetector.Detect(param, targetList...)
 	return ok
 }
 
 func findDirectionOfTaskKey(s *StrategyImpl, sctx *strategy.StrategyContext) (int, int, error) {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 
-	script.Scroll(20, "up")
-	sleeper.Sleep(50)
-	script.Scroll(5, "down")
-	sleeper.Sleep(50)
-	script.ChangeCameraAngleForY(x, y, 8, 7.8) // 镜头向下一点，避免人物遮挡BOSS（可能因此无法识别boss屁股，可以增加训练或者不改y轴，让x轴错开一点角度）
+	script.Scroll(s.Input(), 20, "up")
+	sleeper.Sleep(s.Context.Ctx, 50)
+	script.Scroll(s.Input(), 5, "down")
+	sleeper.Sleep(s.Context.Ctx, 50)
+	script.ChangeCameraAngleForY(s.Input(), x, y, 8, 7.8) // 镜头向下一点，避免人物遮挡BOSS（可能因此无法识别boss屁股，可以增加训练或者不改y轴，让x轴错开一点角度）
 
 	// 获取钥匙的方向（不论判断为左或右，都有可能就在自己身边）
 	direction, bossKeyClassId, err := findDirectionOfTaskKey0(s, sctx)
@@ -227,19 +221,20 @@ func findDirectionOfTaskKey0(s *StrategyImpl, sctx *strategy.StrategyContext) (i
 	if err != nil {
 		return -1, -1, err
 	}
-	log.Printf("[%s-%s] 检测到BOSS头顶钥匙为 %s\n", s.GetName(), s.GetMode(), classes[bossKeyClassId])
-	sleeper.Sleep(500)
-	x, y := robotgo.Location()
+	log.Printf("[%s-%s] 检测到BOSS头顶钥匙为 %s\n", s.GetName(), s.GetMode(), s.dnnDetector.ClassName(bossKeyClassId))
+	sleeper.Sleep(s.Context.Ctx, 500)
+	x, y := s.Input().Location()
 
 	direction := -2 // -2:未命中 -1:左边 0:身后 1:右边
 
 	// 转身180看看有没有
-	script.ChangeCameraAngleForX(x, y, -180, 3.24)
+	script.ChangeCameraAngleForX(s.Input(), x, y, -180, 3.24)
 
 	img, _ := sctx.Game.GetScreenshotMatRGB()
 	defer img.Close()
-	param := detector.NewDNNDetectParam(img, 0.5, 0.45)
-	_, keyClassIdList, _ := s.dnnDetector.Detect(param, 1, 2, 3)
+	param := detector.NewDNNDefaultParam(img)
+	keys, _ := s.dnnDetector.Detect(param, 1, 2, 3)
+	keyClassIdList := detector.ClassIDs(keys)
 	if len(keyClassIdList) > 0 {
 		log.Printf("[%s-%s] 已转向到:180 识别到钥匙数:%d 类值:%s\n", s.GetName(), s.GetMode(), len(keyClassIdList), fmt.Sprint(keyClassIdList))
 	} else {
@@ -247,8 +242,8 @@ func findDirectionOfTaskKey0(s *StrategyImpl, sctx *strategy.StrategyContext) (i
 	}
 
 	// 先回正
-	script.ChangeCameraAngleForX(x, y, 180, 3.24)
-	sleeper.Sleep(100)
+	script.ChangeCameraAngleForX(s.Input(), x, y, 180, 3.24)
+	sleeper.Sleep(s.Context.Ctx, 100)
 
 	if len(keyClassIdList) == 1 && keyClassIdList[0] != bossKeyClassId {
 		direction = 0
@@ -257,14 +252,15 @@ func findDirectionOfTaskKey0(s *StrategyImpl, sctx *strategy.StrategyContext) (i
 
 		j := 0
 		for i := range list {
-			script.ChangeCameraAngleForX(x, y, list[i], 3.24)
+			script.ChangeCameraAngleForX(s.Input(), x, y, list[i], 3.24)
 			j++
 
 			img, _ := sctx.Game.GetScreenshotMatRGB()
 			defer img.Close()
 
-			param := detector.NewDNNDetectParam(img, 0.5, 0.45)
-			_, keyClassIdList, _ := s.dnnDetector.Detect(param, 1, 2, 3)
+			param := detector.NewDNNDefaultParam(img)
+			keys, _ := s.dnnDetector.Detect(param, 1, 2, 3)
+			keyClassIdList := detector.ClassIDs(keys)
 			if len(keyClassIdList) > 0 {
 				log.Printf("[%s-%s] 已转向到:%d 识别到钥匙数:%d 类值:%s\n", s.GetName(), s.GetMode(), list[i], len(keyClassIdList), fmt.Sprint(keyClassIdList))
 			} else {
@@ -291,9 +287,9 @@ func findDirectionOfTaskKey0(s *StrategyImpl, sctx *strategy.StrategyContext) (i
 		// 回正视角
 		switch j {
 		case 1:
-			script.ChangeCameraAngleForX(x, y, 30, 3.24)
+			script.ChangeCameraAngleForX(s.Input(), x, y, 30, 3.24)
 		case 2:
-			script.ChangeCameraAngleForX(x, y, -30, 3.24)
+			script.ChangeCameraAngleForX(s.Input(), x, y, -30, 3.24)
 		}
 	}
 
@@ -315,13 +311,13 @@ func findDirectionOfTaskKey0(s *StrategyImpl, sctx *strategy.StrategyContext) (i
 // -------------------------------------------------- 应对BOSS战（找BOSS） ----------------------------------------------------
 
 func findAndFaceBoss(s *StrategyImpl, sctx *strategy.StrategyContext, angle int) bool {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 
 	var boss image.Rectangle
-	ok, _ := utils.NewTicker(20*time.Second, 200*time.Millisecond, func() (bool, error) {
+	ok, _ := utils.NewTicker(s.Context.Ctx, 20*time.Second, 200*time.Millisecond, func() (bool, error) {
 		rect, err := findBoss(s, sctx)
 		if err != nil {
-			script.ChangeCameraAngleForX(x, y, angle, 3.24) // 找不到就转向
+			script.ChangeCameraAngleForX(s.Input(), x, y, angle, 3.24) // 找不到就转向
 			return false, nil
 		}
 		boss = rect
@@ -336,18 +332,18 @@ func findAndFaceBoss(s *StrategyImpl, sctx *strategy.StrategyContext, angle int)
 	// 一次定位：尝试面向BOSS
 	center := utils.GetCenter(boss)
 	angle = utils.GetAngle(image.Point{X: x, Y: y}, center, 14.2)
-	script.ChangeCameraAngleForX(x, y, angle, 3.24)
-	sleeper.Sleep(200)
+	script.ChangeCameraAngleForX(s.Input(), x, y, angle, 3.24)
+	sleeper.Sleep(s.Context.Ctx, 200)
 
 	// 二次定位（因为第一次可能只看到一个角，转的角度并非正对boss）
 	boss, err := findBoss(s, sctx)
 	if err == nil {
 		center = utils.GetCenter(boss)
 		angle = utils.GetAngle(image.Point{X: x, Y: y}, center, 14.2)
-		script.ChangeCameraAngleForX(x, y, angle, 3.24)
+		script.ChangeCameraAngleForX(s.Input(), x, y, angle, 3.24)
 	}
 	log.Printf("[%s-%s] 已转正面向BOSS\n", s.GetName(), s.GetMode())
-	sleeper.Sleep(200)
+	sleeper.Sleep(s.Context.Ctx, 200)
 	return true
 }
 
@@ -356,15 +352,15 @@ func findBoss(s *StrategyImpl, sctx *strategy.StrategyContext) (image.Rectangle,
 	mat, _ := sctx.Game.GetScreenshotMatRGB()
 	defer mat.Close()
 
-	param := detector.NewDNNDetectParam(mat, 0.5, 0.1)
-	rectList, classIdList, ok := s.dnnDetector.Detect(param, 0)
+	param := detector.NewDNNDetectParam(mat, 0, 0.1) // 置信度使用模型推荐值
+	list, ok := s.dnnDetector.Detect(param, 0)
 	if !ok {
 		return image.Rectangle{}, errors.New("无法识别Boss")
 	}
 
-	for i := range classIdList {
-		if classIdList[i] == 0 {
-			return rectList[i], nil
+	for _, item := range list {
+		if item.ClassID == 0 {
+			return item.Rect, nil
 		}
 	}
 	return image.Rectangle{}, errors.New("无法识别Boss")
@@ -375,8 +371,8 @@ func findBossKey(s *StrategyImpl, sctx *strategy.StrategyContext) (image.Rectang
 	mat, _ := sctx.Game.GetScreenshotMatRGB()
 	defer mat.Close()
 
-	param := detector.NewDNNDetectParam(mat, 0.5, 0.45)
-	rectList, classIdList, ok := s.dnnDetector.Detect(param, 1, 2, 3)
+	param := detector.NewDNNDefaultParam(mat)
+	keys, ok := s.dnnDetector.Detect(param, 1, 2, 3)
 	if !ok {
 		return image.Rectangle{}, 0, errors.New("无法识别Boss钥匙")
 	}
@@ -386,17 +382,17 @@ func findBossKey(s *StrategyImpl, sctx *strategy.StrategyContext) (image.Rectang
 
 	// 位于屏幕最高处的那个key视为boss头顶的key，如果不行就得换成 findSwordKey 的根据x距离最接近的查找方法
 	minY := 9999
-	for i := range rectList {
-		if classIdList[i] != 1 && classIdList[i] != 2 && classIdList[i] != 3 {
+	for _, item := range keys {
+		if item.ClassID != 1 && item.ClassID != 2 && item.ClassID != 3 {
 			continue
 		}
-		rect := rectList[i]
+		rect := item.Rect
 		if rect.Min.Y >= minY { // 屏幕左上角是(0, 0)，因此rect.y > minY，代表rect在屏幕下面的位置
 			continue
 		}
 		minY = rect.Min.Y
 		key = rect
-		keyClassId = classIdList[i]
+		keyClassId = item.ClassID
 	}
 	return key, keyClassId, nil
 }
@@ -1,176 +1,87 @@
 package sheep2
 
 import (
-	_ "embed"
 	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"star-map-tool/assets"
 	"star-map-tool/internal/detector"
 	"star-map-tool/internal/pkg/script"
 	"star-map-tool/internal/pkg/sleeper"
 	"star-map-tool/internal/pkg/utils"
 	"star-map-tool/internal/strategy"
 	"star-map-tool/internal/strategy/preset"
-	"sync/atomic"
 	"time"
-
-	"github.com/go-vgo/robotgo"
 )
 
 // 策略算是单例的，上下文每次执行都是新的
 type StrategyImpl struct {
-	enable  int32
-	context *strategy.StrategyContext
+	strategy.BaseStrategy
+
+	dnnDetector detector.DNNDetector
+	script      script.Script
+}
 
-	colorDetector detector.ColorDetector
-	dnnDetector   detector.DNNDetector
-	script        script.Script
+func init() {
+	strategy.Register(NewStrategyImpl())
 }
 
 func NewStrategyImpl() strategy.Strategy {
 	return &StrategyImpl{
-		enable: 1,
+		BaseStrategy: strategy.NewBaseStrategy("衰败深处", "困难"),
 	}
 }
 
-func (s *StrategyImpl) GetName() string {
-	return "衰败深处"
-}
-
-func (s *StrategyImpl) GetMode() string {
-	return "困难"
-}
-
-func (s *StrategyImpl) IsEnable() bool {
-	return atomic.LoadInt32(&s.enable) == 1
-}
-
-func (s *StrategyImpl) Enable() {
-	atomic.StoreInt32(&s.enable, 1)
-}
-
-func (s *StrategyImpl) Disable(reason int32) {
-	// -1: 终止(超时)、-2: 执行失败、-3: 死亡、0: 执行结束、1: 可用
-	atomic.StoreInt32(&s.enable, reason)
-}
-
-func (s *StrategyImpl) StartDeathCheck(ctx *strategy.StrategyContext) {
-	atomic.StoreInt32(&ctx.DeathCheckFlag, 1) // 开启死亡检测
-}
-
-func (s *StrategyImpl) StopDeathCheck(ctx *strategy.StrategyContext) {
-	atomic.StoreInt32(&ctx.DeathCheckFlag, 0) // 关闭死亡检测
-}
-
-//go:embed ..\..\..\..\assets\models\sbsc\best.onnx
-var modeFile []byte
-
-func (s *StrategyImpl) Init() {
-	s.colorDetector = detector.NewColorDetector()
-	s.dnnDetector = detector.NewDNNDetector("", modeFile)
-	s.script = script.NewDefaultScript()
-}
-
-func (s *StrategyImpl) Execute(sctx *strategy.StrategyContext, data interface{}) bool {
-	s.context = sctx // 每次的策略上下文都是新的
-	s.context.Attrs["START_TIME"] = time.Now()
-	s.Enable()
-	go s.runDeatchCheck()
-
-	// 目前无法识别是否到达指定地点，全是按照时间进行的，后续可以追加训练一些标志物识别的模型
-	var operationList []script.Operation
-	operationList = append(operationList, s.goToDungeon()...)
-	operationList = append(operationList, s.startDungeon()...)
-	operationList = append(operationList, s.handleScence1()...)
-	operationList = append(operationList, s.handleScence2()...)
-	operationList = append(operationList, s.handleScence3()...)
-	operationList = append(operationList, s.handleScence4()...)
-	operationList = append(operationList, s.handleScence5()...)
-	operationList = append(operationList, s.handleBossScence()...)
-	return s.run(operationList)
-}
-
-func (s *StrategyImpl) Abort(sign string) {
-	s.Disable(-1)
-}
-
-func (s *StrategyImpl) run(list []script.Operation) bool {
-	for _, op := range list {
-		if !s.IsEnable() {
-			s.exitDungeon()
-			return false
-		}
-		ok := op()
-		if !ok {
-			s.Disable(-2)
-			s.exitDungeon()
-			return false
-		}
+func (s *StrategyImpl) GetMetadata() strategy.Metadata {
+	return strategy.Metadata{
+		Roles:       "让出奶位",
+		Description: "部分环节存在奶量压力!",
+		Timeout:     10 * time.Minute,
+		Interval:    10 * time.Second,
+		Aliases:     []string{"sheep2", "sbsc2"},
 	}
-	s.Disable(0) // 让子线程有停止的机会
-	return true
 }
 
-func (s *StrategyImpl) runDeatchCheck() {
-	// 死亡处理：一般只检测途中，死亡后直接退出（如果不退出需要更复杂的操作去识别、修正行为）
-	running := false
-	for {
-		flag := atomic.LoadInt32(&s.context.DeathCheckFlag)
-		if running && flag == 0 {
-			// 逻辑内关闭死亡检测 - 通常到达BOSS战才会关闭
-			return
-		} else if !s.IsEnable() {
-			// 有其他逻辑中断策略执行，停止死亡检测
-			return
-		} else if flag == 0 {
-			sleeper.Sleep(200)
-			continue
+func (s *StrategyImpl) Init() error {
+	s.BaseStrategy.Init()
+	if s.dnnDetector == nil { // 模型只加载一次，之后的每次执行都复用
+		bundle, err := detector.LoadModelBundle(assets.Models, "models/sbsc")
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("[%s-%s] 模型缺失, 编译前需将 %s 放入 assets/models/sbsc 目录: %w", s.GetName(), s.GetMode(), detector.ModelFileName, err)
 		}
-		if !running {
-			running = true
+		if err != nil {
+			return fmt.Errorf("[%s-%s] %w", s.GetName(), s.GetMode(), err)
 		}
-
-		_, _, ok := preset.GetPlayerHealthArea(*s.context.Game, s.colorDetector)
-		flag = atomic.LoadInt32(&s.context.DeathCheckFlag)
-		if !ok && flag == 1 { // 已死亡
-			log.Printf("[%s-%s] 未检测到玩家血条,认定为已死亡(即将执行P出逻辑)\n", s.GetName(), s.GetMode())
-			running = false
-			s.Disable(-3) // 交给主线程去退出对局
-			return
+		if s.dnnDetector, err = detector.NewDNNDetector(bundle, detector.DefaultDNNConfig); err != nil {
+			return fmt.Errorf("[%s-%s] %w", s.GetName(), s.GetMode(), err)
 		}
-		sleeper.Sleep(200)
 	}
+	s.script = script.NewDefaultScript(s.ColorDetector, func() *strategy.StrategyContext { return s.Context })
+	return nil
 }
 
-func (s *StrategyImpl) exitDungeon() {
-	enable := atomic.LoadInt32(&s.enable)
-	if enable == -2 || enable == -3 {
-		robotgo.Click() // 有可能小月卡弹框
-		sleeper.Sleep(200)
-
-		// 直接p，死亡状态按p是无效的，如果能退就退了
-		robotgo.KeyTap("p")
-		robotgo.MoveClick(794, 579)
-
-		// p不出去就点死亡时出现的退出按钮
-		robotgo.MoveClick(1179, 67)
-		robotgo.MoveClick(794, 579)
-		log.Printf("[%s-%s] 已执行副本退出逻辑\n", s.GetName(), s.GetMode())
-	}
+func (s *StrategyImpl) Execute(sctx *strategy.StrategyContext, data interface{}) strategy.Outcome {
+	// 目前无法识别是否到达指定地点，全是按照时间进行的，后续可以追加训练一些标志物识别的模型
+	return s.Launch(sctx, func() []strategy.Scene {
+		return []strategy.Scene{
+			{Name: "前往地下城", Operations: s.goToDungeon()},
+			{Name: "开启地下城", Operations: s.startDungeon()},
+			{Name: "第1个关卡", Operations: s.handleScence1()},
+			{Name: "第2个关卡", Operations: s.handleScence2()},
+			{Name: "第3个关卡", Operations: s.handleScence3()},
+			{Name: "第4个关卡", Operations: s.handleScence4()},
+			{Name: "第5个关卡", Operations: s.handleScence5()},
+			{Name: "Boss关卡", Operations: s.handleBossScence()},
+		}
+	})
 }
 
 func (s *StrategyImpl) handleBossScence() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		// 检测是否进入boss房间
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(20*time.Second, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
-				}
-				_, _, ok := GetBossArea(*sctx.Game, s.colorDetector)
-				return ok, nil
-			}, true)
-		}, func() *strategy.StrategyContext { return s.context }),
+		s.script.WaitUntil(GetBossArea, 20*time.Second, 1*time.Second, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Log(s.GetName(), s.GetMode(), "执行Boss关卡"),
 		s.script.Wait(4_000),
 
@@ -180,25 +91,25 @@ func (s *StrategyImpl) handleBossScence() []script.Operation {
 
 		// 持续检查是否进入二阶段
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			utils.NewTicker(4*time.Minute, 1*time.Second, func() (bool, error) {
+			utils.NewTicker(sctx.Ctx, 4*time.Minute, 1*time.Second, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetRebirthLightArea(*sctx.Game, s.colorDetector)
+				_, ok := preset.GetRebirthLightArea(*sctx.Game, s.ColorDetector)
 				if ok {
-					robotgo.MoveClick(1123, 700)
+					s.Input().MoveClick(1123, 700)
 				}
-				_, _, ok = preset.GetBossGrayHealth(*sctx.Game, s.colorDetector)
+				_, ok = preset.GetBossGrayHealth(*sctx.Game, s.ColorDetector)
 				return ok, nil
 			}, true)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Wait(200),
 		s.script.TapOnce("h"), // 过场动画中关H没用，得在外面关
 		s.script.ExecTask(func(scxt *strategy.StrategyContext) (bool, error) {
 			s.StartDeathCheck(scxt)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 
 		// 通过转向找到目标钥匙
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
@@ -212,12 +123,12 @@ func (s *StrategyImpl) handleBossScence() []script.Operation {
 				return true, nil
 			}
 			return false, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		// 前往目标钥匙
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
 			ok := GotoTaskKey(s, sctx, 30_000)
 			return ok, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 
 		// 等待钥匙被AI击败后，找到并前往门的方向
 		s.script.Wait(6_000),
@@ -237,54 +148,56 @@ func (s *StrategyImpl) handleBossScence() []script.Operation {
 			}
 			ok = GotoWall(s, sctx, direction, 40_000)
 			return ok, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.ExecTask(func(scxt *strategy.StrategyContext) (bool, error) {
 			s.StopDeathCheck(scxt)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.TapOnce("h"),
 		s.script.ExecTask(func(sc *strategy.StrategyContext) (bool, error) {
 			for range 7 {
-				script.ChangeCameraAngleForX(x, y, -50, 3.24)
+				script.ChangeCameraAngleForX(s.Input(), x, y, -50, 3.24)
 			}
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 
 		// 持续检查是否进入结算阶段
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(3*time.Minute, 1*time.Second, func() (bool, error) {
+			return utils.NewTicker(sctx.Ctx, 3*time.Minute, 1*time.Second, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetBossHealth(*sctx.Game, s.colorDetector)
-				if !ok {
-					log.Printf("[%s-%s] 检测到BOSS即将死亡...\n", s.GetName(), s.GetMode())
+				// 红色血条消失(或变为灰色)代表BOSS即将死亡
+				health, _ := preset.GetBossHealthPercent(*sctx.Game)
+				if health.State == detector.HealthRed {
+					return false, nil
 				}
-				return !ok, nil
+				log.Printf("[%s-%s] 检测到BOSS即将死亡... (血条: %v)\n", s.GetName(), s.GetMode(), health)
+				return true, nil
 			}, false)
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Wait(10_000),
 		s.script.Log(s.GetName(), s.GetMode(), "开始检测结算画面..."),
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(12*time.Minute, 1*time.Second, func() (bool, error) {
+			return utils.NewTicker(sctx.Ctx, 12*time.Minute, 1*time.Second, func() (bool, error) {
 				if !s.IsEnable() {
 					return false, errors.New("策略已停止")
 				}
-				_, _, ok := preset.GetNextArea(*sctx.Game, s.colorDetector)
+				_, ok := preset.GetNextArea(*sctx.Game, s.ColorDetector)
 				if ok {
-					robotgo.MoveClick(635, 715)
-					sleeper.Sleep(200)
-					robotgo.MoveClick(935, 735)
+					s.Input().MoveClick(635, 715)
+					sleeper.Sleep(sctx.Ctx, 200)
+					s.Input().MoveClick(935, 735)
 				}
 				return ok, nil
 			}, false)
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Log(s.GetName(), s.GetMode(), "检测到下一步按钮,正在正常退出副本..."),
 	}
 }
 
 func (s *StrategyImpl) handleScence5() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Log(s.GetName(), s.GetMode(), "执行第5个关卡(特征:胖子)"),
 
@@ -299,9 +212,9 @@ func (s *StrategyImpl) handleScence5() []script.Operation {
 			if !s.IsEnable() {
 				return false, errors.New("策略已停止")
 			}
-			_, _, ok := preset.GetBossConditionArea(*sctx.Game, s.colorDetector)
+			_, ok := preset.GetBossConditionArea(*sctx.Game, s.ColorDetector)
 			return ok, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Move([]string{"d", "shift"}, 5_000), // 这里会被吸走，全凭移动 + ai奶尽可能幸存
 
 		s.script.ChangeCameraAngleForX(x, y, -38, 3.24),
@@ -313,19 +226,19 @@ func (s *StrategyImpl) handleScence5() []script.Operation {
 		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
 			s.StopDeathCheck(sctx)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.MoveAndKeep([]string{"w", "shift"}, 10_000, 1_000, func(sctx *strategy.StrategyContext) (bool, error) {
 			if !s.IsEnable() {
 				return false, errors.New("策略已停止")
 			}
-			_, _, ok := preset.GetPlayerHealthArea(*sctx.Game, s.colorDetector)
+			_, ok := preset.GetPlayerHealthArea(*sctx.Game, s.ColorDetector)
 			return !ok, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 	}
 }
 
 func (s *StrategyImpl) handleScence4() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Log(s.GetName(), s.GetMode(), "执行第4个关卡(特征:羊)"),
 
@@ -337,23 +250,23 @@ func (s *StrategyImpl) handleScence4() []script.Operation {
 		s.script.Move([]string{"a", "shift"}, 2000),
 		s.script.Wait(10_000),
 		s.script.Move([]string{"d"}, 1050),
-		s.script.MoveAndOnce([]string{"w", "shift"}, 11_000, func(*strategy.StrategyContext) (bool, error) {
-			robotgo.KeyTap("e")
-			sleeper.SleepBusyLoop(600)
-			robotgo.KeyTap("e")
-			sleeper.Sleep(1000)
+		s.script.MoveAndOnce([]string{"w", "shift"}, 11_000, func(sctx *strategy.StrategyContext) (bool, error) {
+			s.Input().KeyTap("e")
+			sleeper.SleepBusyLoop(sctx.Ctx, 600)
+			s.Input().KeyTap("e")
+			sleeper.Sleep(sctx.Ctx, 1000)
 
 			// 游戏特性: e之后不跟shift，会变为走路
-			robotgo.KeyUp("shift")
-			robotgo.KeyDown("shift")
+			s.Input().KeyUp("shift")
+			s.Input().KeyDown("shift")
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 	}
 	// min 154 215 0  max 255 255 59  可以识别到传送门 有需要可以通过颜色识别并导航
 }
 
 func (s *StrategyImpl) handleScence3() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Log(s.GetName(), s.GetMode(), "执行第3个关卡(特征:姆克)"),
 
@@ -369,7 +282,7 @@ func (s *StrategyImpl) handleScence3() []script.Operation {
 }
 
 func (s *StrategyImpl) handleScence2() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Log(s.GetName(), s.GetMode(), "执行第2个关卡(特征:羊)"),
 
@@ -394,7 +307,7 @@ func (s *StrategyImpl) handleScence2() []script.Operation {
 }
 
 func (s *StrategyImpl) handleScence1() []script.Operation {
-	x, y := robotgo.Location()
+	x, y := s.Input().Location()
 	return []script.Operation{
 		s.script.Log(s.GetName(), s.GetMode(), "执行第1个关卡(特征:蜥蜴)"),
 		s.script.Wait(2000),
@@ -407,14 +320,14 @@ func (s *StrategyImpl) handleScence1() []script.Operation {
 		s.script.Move([]string{"w", "shift"}, 2400),
 		s.script.ChangeCameraAngleForX(x, y, -90, 3.24),
 		s.script.ChangeCameraAngleForX(x, y, -22, 3.24),
-		s.script.MoveAndOnce([]string{"w", "shift"}, 4500, func(*strategy.StrategyContext) (bool, error) {
-			robotgo.KeyTap("e")
-			sleeper.SleepBusyLoop(600)
-			robotgo.KeyTap("e")
-			sleeper.Sleep(200)
+		s.script.MoveAndOnce([]string{"w", "shift"}, 4500, func(sctx *strategy.StrategyContext) (bool, error) {
+			s.Input().KeyTap("e")
+			sleeper.SleepBusyLoop(sctx.Ctx, 600)
+			s.Input().KeyTap("e")
+			sleeper.Sleep(sctx.Ctx, 200)
 
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 		s.script.Wait(25_000),
 
 		s.script.Move([]string{"s"}, 1000),
@@ -427,35 +340,35 @@ func (s *StrategyImpl) handleScence1() []script.Operation {
 			d := sctx.Attrs["_scence1_direction"].(int32)
 			if d == 0 {
 				d = 1
-				robotgo.KeyDown("ctrl")
-				robotgo.KeyDown("w")
+				s.Input().KeyDown("ctrl")
+				s.Input().KeyDown("w")
 			}
 
-			_, _, ok := GetSwordKey1Area(*sctx.Game, s.colorDetector)
+			_, ok := GetSwordKey1Area(*sctx.Game, s.ColorDetector)
 			if time.Since(startTime) < 12*time.Second { // 12秒的机会去不停的找剑，找不到就继续往后走，找到就恢复往前走
 				if !ok {
-					robotgo.KeyUp("w")
-					robotgo.KeyUp("ctrl")
-					robotgo.KeyDown("s")
-					sleeper.Sleep(1500)
-					robotgo.KeyUp("s")
+					s.Input().KeyUp("w")
+					s.Input().KeyUp("ctrl")
+					s.Input().KeyDown("s")
+					sleeper.Sleep(sctx.Ctx, 1500)
+					s.Input().KeyUp("s")
 				} else {
 					d = 0
 				}
 			} else {
 				if !ok {
-					sleeper.Sleep(1800)
-					robotgo.KeyUp("w")
-					robotgo.KeyUp("ctrl")
+					sleeper.Sleep(sctx.Ctx, 1800)
+					s.Input().KeyUp("w")
+					s.Input().KeyUp("ctrl")
 					log.Printf("[%s-%s] 光墙开启成功\n", s.GetName(), s.GetMode())
 					return true, nil
 				}
 			}
 			return false, nil
 		}, func() *strategy.StrategyContext {
-			s.context.Attrs["_scence1_direction"] = int32(0)
-			s.context.Attrs["_scence1_start_time"] = time.Now()
-			return s.context
+			s.Context.Attrs["_scence1_direction"] = int32(0)
+			s.Context.Attrs["_scence1_start_time"] = time.Now()
+			return s.Context
 		}),
 		s.script.ChangeCameraAngleForY(x, y, -45, 7.8),
 		s.script.ChangeCameraAngleForX(x, y, 2, 3.24),
@@ -465,85 +378,61 @@ func (s *StrategyImpl) handleScence1() []script.Operation {
 func (s *StrategyImpl) startDungeon() []script.Operation {
 	return []script.Operation{
 		s.script.Wait(2000),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			ret, err := utils.NewTicker(20*time.Second, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
-				}
-				_, _, ok := preset.GetDungeonExitArea(*sctx.Game, s.colorDetector) // todo: 这里有问题
-				return ok, nil
-			}, true)
-			if !ret {
-				log.Printf("[%s-%s] 检测到未进入地下城\n", s.GetName(), s.GetMode())
-			} else {
-				log.Printf("[%s-%s] 检测到已进入地下城\n", s.GetName(), s.GetMode())
-			}
-			return ret, err
-		}, func() *strategy.StrategyContext { return s.context }),
+		s.script.WaitUntil(preset.GetDungeonExitArea, 20*time.Second, 1*time.Second, func() *strategy.StrategyContext { return s.Context }), // todo: 这里有问题
+		s.script.Log(s.GetName(), s.GetMode(), "检测到已进入地下城"),
 		s.script.Wait(3000),
 		s.script.Move([]string{"w"}, 1200),
 		s.script.TapOnce("f"),
 		s.script.Log(s.GetName(), s.GetMode(), "正在开启地下城..."),
 		s.script.Wait(200),
 		s.script.MouseMoveClick(810, 665),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			ret, err := utils.NewTicker(15*time.Second, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
-				}
-				_, _, ok := preset.GetDungeonRunningArea(*sctx.Game, s.colorDetector)
-				return ok, nil
-			}, true)
-			if !ret {
-				log.Printf("[%s-%s] 检测到未开启地下城\n", s.GetName(), s.GetMode())
-			} else {
-				log.Printf("[%s-%s] 检测到已开启地下城\n", s.GetName(), s.GetMode())
-			}
-			return ret, err
-		}, func() *strategy.StrategyContext { return s.context }),
+		s.script.WaitUntil(preset.GetDungeonRunningArea, 15*time.Second, 1*time.Second, func() *strategy.StrategyContext { return s.Context }),
+		s.script.Log(s.GetName(), s.GetMode(), "检测到已开启地下城"),
 		s.script.ExecTask(func(scxt *strategy.StrategyContext) (bool, error) {
 			s.StartDeathCheck(scxt)
 			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+		}, func() *strategy.StrategyContext { return s.Context }),
 	}
 }
 
 func (s *StrategyImpl) goToDungeon() []script.Operation {
+	// 检测到异常队伍时先退出队伍，再重新进入
 	return []script.Operation{
-		// 检查是否在地下城入口
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			return utils.NewTicker(10*time.Second, 1*time.Second, func() (bool, error) {
-				if !s.IsEnable() {
-					return false, errors.New("策略已停止")
+		script.Retry(script.Sequence(
+			// 检查是否在地下城入口
+			s.script.WaitUntil(preset.GetMainArea, 10*time.Second, 1*time.Second, func() *strategy.StrategyContext { return s.Context }),
+			s.script.TapOnce("f"),
+			s.script.Wait(200),
+			s.script.MouseMoveClick(124, 208),
+			s.script.Wait(200),
+			s.script.MouseMoveClick(1000, 690),
+			s.script.Wait(200),
+			s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
+				if list, ok := preset.GetDungeonQueueArea(*sctx.Game, s.ColorDetector); !ok || len(list) != 2 {
+					return false, nil
 				}
-				_, _, ok := preset.GetMainArea(*sctx.Game, s.colorDetector)
-				return ok, nil
-			}, true)
-		}, func() *strategy.StrategyContext { return s.context }),
-		s.script.TapOnce("f"),
-		s.script.Wait(200),
-		s.script.MouseMoveClick(124, 208),
-		s.script.Wait(200),
-		s.script.MouseMoveClick(1000, 690),
-		s.script.Wait(200),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			if _, sizeList, ok := preset.GetDungeonQueueArea(*sctx.Game, s.colorDetector); !ok || len(sizeList) != 2 {
-				return false, nil
-			}
-			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
-		s.script.MouseMoveClick(1185, 735),
-		s.script.MouseMove(0, 0),
-		s.script.Wait(3 * 1000),
-		s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
-			if _, sizeList, ok := preset.GetDungeonQueueArea(*sctx.Game, s.colorDetector); ok && len(sizeList) == 2 {
-				log.Printf("[%s-%s] 检测到异常队伍,正在执行退出队伍操作...\n", s.GetName(), s.GetMode())
-				robotgo.KeyTap("esc")
-				script.HandleAbnormalTeam(sctx.Game)
-				s.run(s.goToDungeon())
-			}
-			return true, nil
-		}, func() *strategy.StrategyContext { return s.context }),
+				return true, nil
+			}, func() *strategy.StrategyContext { return s.Context }),
+			s.script.MouseMoveClick(1185, 735),
+			s.script.MouseMove(0, 0),
+			s.script.Wait(3*1000),
+			script.Fallback(
+				// 检查是否进入了异常队伍
+				s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
+					if list, ok := preset.GetDungeonQueueArea(*sctx.Game, s.ColorDetector); ok && len(list) == 2 {
+						log.Printf("[%s-%s] 检测到异常队伍,正在执行退出队伍操作...\n", s.GetName(), s.GetMode())
+						return false, nil
+					}
+					return true, nil
+				}, func() *strategy.StrategyContext { return s.Context }),
+				// 退出队伍后由 Retry 重新进入
+				s.script.ExecTask(func(sctx *strategy.StrategyContext) (bool, error) {
+					s.Input().KeyTap("esc")
+					script.HandleAbnormalTeam(sctx.Game)
+					return false, nil
+				}, func() *strategy.StrategyContext { return s.Context }),
+			),
+		), 3, 2*time.Second),
 		s.script.Log(s.GetName(), s.GetMode(), "正在进入地下城..."),
 	}
 }
@@ -1,39 +1,30 @@
 package sheep3
 
 import (
-	"image"
-	"image/color"
 	"star-map-tool/internal/detector"
 	"star-map-tool/internal/game"
-	"star-map-tool/internal/pkg/utils"
+	"star-map-tool/internal/strategy/preset"
 )
 
 var (
 	// 中间 - 开门的光剑
 	Sword1Area  = []int{288, 0, 958, 800}
-	Sword1Color = []color.RGBA{{20, 40, 200, 0}, {40, 90, 255, 0}}
+	Sword1Color = detector.MustHSVRange(detector.HSV{H: 20, S: 40, V: 200}, detector.HSV{H: 40, S: 90, V: 255})
 
 	// 中上 - BOSS站姿时角的识别
 	BossArea       = []int{520, 60, 770, 330}
-	BossRangeColor = []color.RGBA{{167, 157, 153, 0}, {255, 255, 255, 0}}
+	BossRangeColor = detector.MustHSVRange(detector.HSV{H: 167, S: 157, V: 153}, detector.HSV{H: 179, S: 255, V: 255})
+
+	// 全屏 - BOSS房间的墙体
+	WallColor = detector.MustHSVRange(detector.HSV{H: 130, S: 90, V: 136}, detector.HSV{H: 149, S: 252, V: 210})
 )
 
 // 获取第一个关卡的开门钥匙标志
-func GetSwordKey1Area(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(Sword1Area[0], Sword1Area[1], Sword1Area[2], Sword1Area[3])
-	mat, _ := game.GetScreenshotMatRGB(Sword1Area[0], Sword1Area[1], w, h)
-	defer mat.Close()
-
-	param := detector.NewColorDetectParam(mat, Sword1Color[0], Sword1Color[1], 60)
-	return colorDetector.Detect(param)
+func GetSwordKey1Area(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return preset.Locate(game, Sword1Area, detector.Color(colorDetector, Sword1Color, 60))
 }
 
 // 获取Boss标志（由于没有对Boss站姿进行训练，只能通过识别角的颜色来进行处理）
-func GetBossArea(game game.Game, colorDetector detector.ColorDetector) ([]image.Rectangle, []float64, bool) {
-	w, h := utils.GetRectSize(BossArea[0], BossArea[1], BossArea[2], BossArea[3])
-	img, _ := game.GetScreenshotMatRGB(BossArea[0], BossArea[1], w, h)
-	defer img.Close()
-
-	param := detector.NewColorDetectParam(img, BossRangeColor[0], BossRangeColor[1], 20)
-	return colorDetector.Detect(param)
+func GetBossArea(game game.Game, colorDetector detector.ColorDetector) ([]detector.Detection, bool) {
+	return preset.Locate(game, BossArea, detector.Color(colorDetector, BossRangeColor, 20))
 }
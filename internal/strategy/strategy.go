@@ -1,44 +1,131 @@
 package strategy
 
 import (
+	"context"
+	"fmt"
 	"star-map-tool/internal/game"
 	"sync"
+	"time"
 )
 
 type Strategy interface {
 	GetName() string
 	GetMode() string
-	Init()
-	Execute(sctx *StrategyContext, data interface{}) bool
+	GetMetadata() Metadata
+	Init() error // 加载模型等资源，失败时不会执行
+	Execute(sctx *StrategyContext, data interface{}) Outcome
 	Abort(sign string)
 }
 
+// 策略自带的刷本建议与推荐参数，配置文件未填写时以此为准
+type Metadata struct {
+	Roles       string        // 职业要求，例如: 让出输出位
+	Description string        // 其他刷本建议
+	Timeout     time.Duration // 推荐的单轮限时，0代表不限时
+	Interval    time.Duration // 推荐的每轮间隔
+	Enable      bool          // 配置文件缺省时是否默认启用
+	Aliases     []string      // 别名，可代替 地图+模式 使用，例如: snake3
+}
+
 type StrategyContext struct {
+	Ctx context.Context // 本轮的上下文，超时或中断时被取消；WithContext 派生的上下文为操作自身的上下文
+	*roundState
+}
+
+// 本轮共享的状态，WithContext 派生的上下文与本轮上下文共用同一份
+type roundState struct {
 	Game   *game.Game
 	Attrs  map[string]any // 不限制存储内容，如果是大型value，自动写入指针
 	RWLock sync.RWMutex   // 提供对于 Attrs 的读写锁，可供其安全读写
 
 	DeathCheckFlag int32 // 0关闭、1打开
 	Step           int32 // 策略执行进度
+
+	errLock sync.Mutex
+	err     error // 操作失败的原因
 }
 
-// 成功、超时、终止、其它
+// 策略结束的原因
+type Reason int32
+
+// 成功、失败、超时、终止、其它、死亡
 const (
-	STRATEGY_REASON_SUCCESS int32 = iota
+	STRATEGY_REASON_SUCCESS Reason = iota
 	STRATEGY_REASON_FAIL
 	STRATEGY_REASON_TIMEOUT
 	STRATEGY_REASON_ABORT
 	STRATEGY_REASON_OTHER
+	STRATEGY_REASON_DEATH
 )
 
-// 执行超时、用户取消
+func (r Reason) String() string {
+	switch r {
+	case STRATEGY_REASON_SUCCESS:
+		return "成功"
+	case STRATEGY_REASON_FAIL:
+		return "执行失败"
+	case STRATEGY_REASON_TIMEOUT:
+		return "超时"
+	case STRATEGY_REASON_ABORT:
+		return "终止"
+	case STRATEGY_REASON_DEATH:
+		return "死亡"
+	default:
+		return "其它"
+	}
+}
+
+// 单轮执行的结果
+type Outcome struct {
+	Reason  Reason
+	Scene   string        // 结束时所在的环节
+	Step    int           // 结束时执行到环节内的第几步
+	Message string        // 失败原因
+	Elapsed time.Duration // 本轮耗时
+}
+
+func (o Outcome) Success() bool {
+	return o.Reason == STRATEGY_REASON_SUCCESS
+}
+
+func (o Outcome) String() string {
+	text := o.Reason.String()
+	if o.Scene != "" && !o.Success() {
+		text += fmt.Sprintf(" [环节:%s 第%d步]", o.Scene, o.Step)
+	}
+	if o.Message != "" {
+		text += " " + o.Message
+	}
+	return text
+}
+
+// 执行超时、副本剩余时间不足、用户取消
 const (
-	STRATEGY_EVENT_TIMEOUT string = "timeout"
-	STRATEGY_EVENT_OTHER   string = "other"
+	STRATEGY_EVENT_TIMEOUT   string = "timeout"
+	STRATEGY_EVENT_TIME_LEFT string = "time-left"
+	STRATEGY_EVENT_OTHER     string = "other"
 )
 
-func NewStrategyContext(game *game.Game) *StrategyContext {
+func NewStrategyContext(ctx context.Context, game *game.Game) *StrategyContext {
 	attrs := make(map[string]interface{})
 
-	return &StrategyContext{Game: game, Attrs: attrs}
+	return &StrategyContext{Ctx: ctx, roundState: &roundState{Game: game, Attrs: attrs}}
+}
+
+// 派生使用 ctx 的上下文，其余状态与本轮共用，用于将限时、竞争等组合器的中断传递给任务
+func (sctx *StrategyContext) WithContext(ctx context.Context) *StrategyContext {
+	return &StrategyContext{Ctx: ctx, roundState: sctx.roundState}
+}
+
+// 记录最近一次操作失败的原因，重试成功后的旧错误会在下一步开始前清除
+func (sctx *StrategyContext) SetError(err error) {
+	sctx.errLock.Lock()
+	defer sctx.errLock.Unlock()
+	sctx.err = err
+}
+
+func (sctx *StrategyContext) Error() error {
+	sctx.errLock.Lock()
+	defer sctx.errLock.Unlock()
+	return sctx.err
 }